@@ -0,0 +1,72 @@
+// Package api defines the JSON documents the dashboard publishes under /api/v1/.
+//
+// v1.schema.json describes the same documents as a JSON Schema.
+// Fields are only ever added within a version; anything else gets a new version.
+package api
+
+import (
+	_ "embed"
+	"time"
+)
+
+// Version is the path component the documents are published under
+const Version = "v1"
+
+// SchemaV1 is the JSON Schema for Index and Repo
+//
+//go:embed v1.schema.json
+var SchemaV1 []byte
+
+// Index is published at /api/v1/index.json
+type Index struct {
+	Version   string    `json:"version"`
+	BuildDate time.Time `json:"build_date"`
+	Since     time.Time `json:"since"`
+	Repos     []RepoRef `json:"repos"`
+}
+
+// RepoRef points from the index to a repository document
+type RepoRef struct {
+	Owner  string `json:"owner"`
+	Name   string `json:"name"`
+	Path   string `json:"path"` // relative to /api/v1/
+	Issues int    `json:"issues"`
+}
+
+// Repo is published at /api/v1/<owner>/<repo>.json
+type Repo struct {
+	Version   string    `json:"version"`
+	BuildDate time.Time `json:"build_date"`
+	Since     time.Time `json:"since"`
+	Owner     string    `json:"owner"`
+	Name      string    `json:"name"`
+	Issues    []Issue   `json:"issues"`
+}
+
+// Issue is an issue or pull request with activity since Repo.Since
+type Issue struct {
+	Number       int            `json:"number"`
+	Kind         string         `json:"kind"`   // "issue" or "pull_request"
+	Status       string         `json:"status"` // "open", "closed", "draft" or "merged"
+	Title        string         `json:"title"`
+	Author       string         `json:"author"`
+	URL          string         `json:"url"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	MergedAt     *time.Time     `json:"merged_at,omitempty"`
+	ReviewStates map[string]int `json:"review_states,omitempty"` // latest review per reviewer, counted by state
+	Timeline     []TimelineItem `json:"timeline"`
+}
+
+// TimelineItem is one thing that happened to an issue, oldest first
+type TimelineItem struct {
+	Kind     string    `json:"kind"` // "comment", "commit", "review" or "event"
+	Time     time.Time `json:"time"`
+	Actor    string    `json:"actor,omitempty"`
+	URL      string    `json:"url,omitempty"`
+	BodyHTML string    `json:"body_html,omitempty"` // comment
+	SHA      string    `json:"sha,omitempty"`       // commit
+	Message  string    `json:"message,omitempty"`   // commit
+	State    string    `json:"state,omitempty"`     // review
+	Event    string    `json:"event,omitempty"`     // event
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"kokkos-dashboard/api"
+)
+
+// toAPIIssue converts the template model of an issue to its published form
+func toAPIIssue(issue Issue, since time.Time) api.Issue {
+	out := api.Issue{
+		Number:       issue.Number,
+		Kind:         "issue",
+		Status:       issue.Status,
+		Title:        issue.Title,
+		Author:       issue.User.Login,
+		URL:          issue.HTMLURL,
+		CreatedAt:    issue.CreatedAt,
+		UpdatedAt:    issue.UpdatedAt,
+		ReviewStates: issue.ReviewStates,
+		Timeline:     []api.TimelineItem{},
+	}
+	if issue.PullRequest != nil {
+		out.Kind = "pull_request"
+	}
+	if issue.PR != nil {
+		out.MergedAt = issue.PR.MergedAt
+	}
+
+	for _, comment := range issue.Comments {
+		out.Timeline = append(out.Timeline, api.TimelineItem{
+			Kind:     "comment",
+			Time:     comment.CreatedAt,
+			Actor:    comment.User.Login,
+			URL:      comment.HTMLURL,
+			BodyHTML: comment.Body,
+		})
+	}
+	for _, commit := range issue.Commits {
+		item := api.TimelineItem{
+			Kind:    "commit",
+			Time:    commit.Commit.Committer.Date,
+			URL:     commit.HTMLURL,
+			SHA:     commit.SHA,
+			Message: commit.Commit.Message,
+		}
+		if commit.Author != nil {
+			item.Actor = commit.Author.Login
+		}
+		out.Timeline = append(out.Timeline, item)
+	}
+	for _, review := range issue.Reviews {
+		if review.SubmittedAt == nil || review.SubmittedAt.Before(since) {
+			continue
+		}
+		item := api.TimelineItem{
+			Kind:  "review",
+			Time:  *review.SubmittedAt,
+			URL:   review.HTMLURL,
+			State: review.State,
+		}
+		if review.User != nil {
+			item.Actor = review.User.Login
+		}
+		out.Timeline = append(out.Timeline, item)
+	}
+	for _, event := range issue.Events {
+		item := api.TimelineItem{
+			Kind:  "event",
+			Time:  event.CreatedAt,
+			Event: event.Event,
+		}
+		if event.Actor != nil {
+			item.Actor = event.Actor.Login
+		}
+		out.Timeline = append(out.Timeline, item)
+	}
+
+	sort.SliceStable(out.Timeline, func(i, j int) bool {
+		return out.Timeline[i].Time.Before(out.Timeline[j].Time)
+	})
+	return out
+}
+
+func writeJSON(v any, name string) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+
+	// bodies are HTML, keep them readable
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+
+	log.Printf("write %d to %s", buf.Len(), name)
+	return os.WriteFile(name, buf.Bytes(), 0644)
+}
+
+// renderAPIRepo writes the api/v1 document for one repo and returns how the index refers to it
+func renderAPIRepo(repo *RepoData, config Config, buildDate time.Time) (api.RepoRef, error) {
+	out := api.Repo{
+		Version:   api.Version,
+		BuildDate: buildDate.UTC(),
+		Since:     config.Since.UTC(),
+		Owner:     repo.Owner,
+		Name:      repo.Repo,
+		Issues:    []api.Issue{},
+	}
+	for _, issue := range repo.Issues {
+		out.Issues = append(out.Issues, toAPIIssue(issue, config.Since))
+	}
+
+	ref := apiRepoRef(repo)
+	apiDir := filepath.Join(config.OutputDir, "api", api.Version)
+	if err := writeJSON(out, filepath.Join(apiDir, filepath.FromSlash(ref.Path))); err != nil {
+		return api.RepoRef{}, err
+	}
+	return ref, nil
+}
+
+// apiRepoRef is how the api/v1 index refers to the document of repo
+func apiRepoRef(repo *RepoData) api.RepoRef {
+	return api.RepoRef{
+		Owner:  repo.Owner,
+		Name:   repo.Repo,
+		Path:   repo.Owner + "/" + repo.Repo + ".json",
+		Issues: len(repo.Issues),
+	}
+}
+
+// renderAPIIndex writes the api/v1 index of the repo documents refs
+func renderAPIIndex(refs []api.RepoRef, config Config, buildDate time.Time) error {
+	index := api.Index{
+		Version:   api.Version,
+		BuildDate: buildDate.UTC(),
+		Since:     config.Since.UTC(),
+		Repos:     refs,
+	}
+	return writeJSON(index, filepath.Join(config.OutputDir, "api", api.Version, "index.json"))
+}
+
+// renderAPI writes the api/v1 documents for the repos in repoKeys order
+func renderAPI(repoKeys []string, repoData map[string]*RepoData, config Config, buildDate time.Time) error {
+	refs := []api.RepoRef{}
+	for _, key := range repoKeys {
+		ref, err := renderAPIRepo(repoData[key], config, buildDate)
+		if err != nil {
+			return err
+		}
+		refs = append(refs, ref)
+	}
+
+	if err := renderAPIIndex(refs, config, buildDate); err != nil {
+		return err
+	}
+
+	apiDir := filepath.Join(config.OutputDir, "api", api.Version)
+	schemaPath := filepath.Join(apiDir, "schema.json")
+	log.Printf("write %d to %s", len(api.SchemaV1), schemaPath)
+	return os.WriteFile(schemaPath, api.SchemaV1, 0644)
+}
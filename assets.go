@@ -0,0 +1,84 @@
+package main
+
+import (
+	"embed"
+	"errors"
+	"html/template"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// builtinAssets are the templates, static files and theme the binary ships with
+//
+//go:embed templates static theme.json
+var builtinAssets embed.FS
+
+// readAsset reads name ("templates/..." or "static/...") from the theme directory,
+// falling back to the built-in copy
+func readAsset(config Config, name string) ([]byte, error) {
+	if config.ThemeDir != "" {
+		data, err := os.ReadFile(filepath.Join(config.ThemeDir, filepath.FromSlash(name)))
+		if err == nil || !errors.Is(err, os.ErrNotExist) {
+			return data, err
+		}
+	}
+	return builtinAssets.ReadFile(name)
+}
+
+// parseAssetTemplates parses templates/<pattern> from the built-in assets and then the theme directory,
+// so a theme file replaces the built-in templates it defines
+func parseAssetTemplates(tmpl *template.Template, config Config, pattern string) (*template.Template, error) {
+	tmpl, err := tmpl.ParseFS(builtinAssets, path.Join("templates", pattern))
+	if err != nil {
+		return nil, err
+	}
+	if config.ThemeDir == "" {
+		return tmpl, nil
+	}
+
+	themeFiles, err := filepath.Glob(filepath.Join(config.ThemeDir, "templates", pattern))
+	if err != nil || len(themeFiles) == 0 {
+		return tmpl, err
+	}
+	return tmpl.ParseFiles(themeFiles...)
+}
+
+// copyStatic writes the built-in static files to dst, then the theme's on top of them
+func copyStatic(config Config, dst string) error {
+	builtinStatic, err := fs.Sub(builtinAssets, "static")
+	if err != nil {
+		return err
+	}
+	if err := copyTree(dst, builtinStatic); err != nil {
+		return err
+	}
+
+	if config.ThemeDir == "" {
+		return nil
+	}
+	themeStatic := filepath.Join(config.ThemeDir, "static")
+	if _, err := os.Stat(themeStatic); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return copyTree(dst, os.DirFS(themeStatic))
+}
+
+// copyTree copies every file in src to dst, replacing existing files
+func copyTree(dst string, src fs.FS) error {
+	return fs.WalkDir(src, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, filepath.FromSlash(name))
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		data, err := fs.ReadFile(src, name)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, 0644)
+	})
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+
+	"kokkos-dashboard/store"
+)
+
+// referencePattern matches what GitHub links in comments: owner/repo#N, #N, @user and commit SHAs.
+// Go has no lookbehind, so the first group is the character before the reference.
+var referencePattern = regexp.MustCompile(`(^|[^\w/@#])(?:([A-Za-z0-9-]+)/([A-Za-z0-9._-]+)#(\d+)|#(\d+)|@([A-Za-z0-9][A-Za-z0-9-]{0,38})|([0-9a-f]{7,40}))\b`)
+
+// references links the GitHub references in the Markdown of one repository, to the dashboard
+// if the issue or pull request is on it and otherwise to GitHub
+type references struct {
+	owner, repo string // of #N and SHAs
+	siteRoot    string
+	webURL      string                // of GitHub, without a trailing slash
+	pages       map[string]store.Repo // repository of the issues and pull requests on the dashboard, by issueKey
+}
+
+// issueKey identifies an issue or pull request; GitHub names are case-insensitive
+func issueKey(owner, repo string, number int) string {
+	return strings.ToLower(fmt.Sprintf("%s/%s#%d", owner, repo, number))
+}
+
+// issueAnchor is the id of an issue or pull request on the dashboard
+func issueAnchor(owner, repo string, number int) string {
+	return fmt.Sprintf("%s-%s-%d", owner, repo, number)
+}
+
+// dashboardPages finds the issues and pull requests in the store, which the dashboard shows
+func dashboardPages(st store.Store) (map[string]store.Repo, error) {
+	repos, err := st.ListRepos()
+	if err != nil {
+		return nil, err
+	}
+	pages := map[string]store.Repo{}
+	for _, repo := range repos {
+		issues, err := st.ListIssues(repo.Owner, repo.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, issue := range issues {
+			pages[issueKey(repo.Owner, repo.Name, issue.Number)] = repo
+		}
+	}
+	return pages, nil
+}
+
+// issueURL links to an issue or pull request
+func (r *references) issueURL(owner, repo string, number int) string {
+	// the page and its anchor are named as stored, whatever the case of the reference
+	if page, ok := r.pages[issueKey(owner, repo, number)]; ok {
+		return fmt.Sprintf("%s%s/%s/#%s", r.siteRoot, page.Owner, page.Name, issueAnchor(page.Owner, page.Name, number))
+	}
+	// GitHub redirects to the pull request if it is one
+	return fmt.Sprintf("%s/%s/%s/issues/%d", r.webURL, owner, repo, number)
+}
+
+// link replaces the references in the text of doc with links, leaving code and existing links alone
+func (r *references) link(doc ast.Node) {
+	var texts []*ast.Text
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		switch node := node.(type) {
+		case *ast.Link, *ast.Image:
+			return ast.SkipChildren
+		case *ast.Text:
+			texts = append(texts, node)
+		}
+		return ast.GoToNext
+	})
+
+	for _, text := range texts {
+		if nodes := r.split(text.Literal); nodes != nil {
+			replaceNode(text, nodes)
+		}
+	}
+}
+
+// split turns text into text and link nodes, or returns nil if it has no references
+func (r *references) split(text []byte) []ast.Node {
+	var nodes []ast.Node
+	start := 0
+	for _, m := range referencePattern.FindAllSubmatchIndex(text, -1) {
+		group := func(i int) string {
+			if m[2*i] < 0 {
+				return ""
+			}
+			return string(text[m[2*i]:m[2*i+1]])
+		}
+
+		var url string
+		switch {
+		case group(4) != "":
+			number, err := strconv.Atoi(group(4))
+			if err != nil {
+				continue
+			}
+			url = r.issueURL(group(2), group(3), number)
+		case group(5) != "":
+			number, err := strconv.Atoi(group(5))
+			if err != nil {
+				continue
+			}
+			url = r.issueURL(r.owner, r.repo, number)
+		case group(6) != "":
+			if strings.HasSuffix(group(6), "-") {
+				continue // usernames don't end with a hyphen, so this is part of something else
+			}
+			url = r.webURL + "/" + group(6)
+		case strings.ContainsAny(group(7), "0123456789") && strings.ContainsAny(group(7), "abcdef"):
+			// a SHA has digits and letters, unlike numbers and most hex-looking words
+			url = fmt.Sprintf("%s/%s/%s/commit/%s", r.webURL, r.owner, r.repo, group(7))
+		default:
+			continue
+		}
+
+		refStart := m[3] // after the character before the reference
+		nodes = append(nodes, &ast.Text{Leaf: ast.Leaf{Literal: text[start:refStart]}})
+		link := &ast.Link{Destination: []byte(url)}
+		ast.AppendChild(link, &ast.Text{Leaf: ast.Leaf{Literal: text[refStart:m[1]]}})
+		nodes = append(nodes, link)
+		start = m[1]
+	}
+	if nodes == nil {
+		return nil
+	}
+	return append(nodes, &ast.Text{Leaf: ast.Leaf{Literal: text[start:]}})
+}
+
+// replaceNode puts nodes in the place of node in its parent
+func replaceNode(node ast.Node, nodes []ast.Node) {
+	parent := node.GetParent()
+	var children []ast.Node
+	for _, child := range parent.GetChildren() {
+		if child != node {
+			children = append(children, child)
+			continue
+		}
+		for _, n := range nodes {
+			n.SetParent(parent)
+			children = append(children, n)
+		}
+	}
+	parent.SetChildren(children)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"kokkos-dashboard/store"
+)
+
+func TestLinkReferences(t *testing.T) {
+	refs := &references{
+		owner:    "kokkos",
+		repo:     "kokkos",
+		siteRoot: "/dashboard/",
+		webURL:   "https://ghes.example.com",
+		pages:    map[string]store.Repo{issueKey("kokkos", "kokkos", 101): {Owner: "kokkos", Name: "kokkos"}},
+	}
+
+	for _, test := range []struct {
+		name, md, want string
+	}{
+		{"issue", "See #12.",
+			`See <a href="https://ghes.example.com/kokkos/kokkos/issues/12" target="_blank">#12</a>.`},
+		{"issue on the dashboard", "Fixed by #101",
+			`Fixed by <a href="/dashboard/kokkos/kokkos/#kokkos-kokkos-101">#101</a>`},
+		{"issue of another repository", "Same as kokkos/kokkos-kernels#7",
+			`Same as <a href="https://ghes.example.com/kokkos/kokkos-kernels/issues/7" target="_blank">kokkos/kokkos-kernels#7</a>`},
+		{"issue of another repository on the dashboard", "Fixed by Kokkos/Kokkos#101",
+			`Fixed by <a href="/dashboard/kokkos/kokkos/#kokkos-kokkos-101">Kokkos/Kokkos#101</a>`},
+		{"mention", "Thanks @crtrott!",
+			`Thanks <a href="https://ghes.example.com/crtrott" target="_blank">@crtrott</a>!`},
+		{"commit", "Broken since 3e4f5a6b",
+			`Broken since <a href="https://ghes.example.com/kokkos/kokkos/commit/3e4f5a6b" target="_blank">3e4f5a6b</a>`},
+		{"email", "Write to dev@kokkos.org",
+			`Write to dev@kokkos.org`},
+		{"hex-only words", "deadbeef and facade, not 12345678",
+			`deadbeef and facade, not 12345678`},
+		{"short hex", "abc1234 but not abc123",
+			`<a href="https://ghes.example.com/kokkos/kokkos/commit/abc1234" target="_blank">abc1234</a> but not abc123`},
+		{"number in a word", "issue#12 and a/b/c#3",
+			`issue#12 and a/b/c#3`},
+		{"code span", "Not `#12` or `@crtrott` or `3e4f5a6b`",
+			"Not <code>#12</code> or <code>@crtrott</code> or <code>3e4f5a6b</code>"},
+		{"existing link", "[#12](https://example.com)",
+			`<a href="https://example.com" target="_blank">#12</a>`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got := strings.TrimSpace(string(mdToHTML([]byte(test.md), refs)))
+			if want := "<p>" + test.want + "</p>"; got != want {
+				t.Errorf("%q renders as\n%s\nwant\n%s", test.md, got, want)
+			}
+		})
+	}
+}
+
+func TestLinkReferencesLeavesCodeBlocks(t *testing.T) {
+	refs := &references{owner: "kokkos", repo: "kokkos", webURL: "https://github.com"}
+	for _, md := range []string{
+		"```\nrevert 3e4f5a6b for #12, @crtrott\n```",
+		"    revert 3e4f5a6b for #12, @crtrott",
+	} {
+		got := string(mdToHTML([]byte(md), refs))
+		if strings.Contains(got, "<a ") || !strings.Contains(got, "revert 3e4f5a6b for #12, @crtrott") {
+			t.Errorf("%q renders as %s", md, got)
+		}
+	}
+}
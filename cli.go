@@ -0,0 +1,449 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime/debug"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"kokkos-dashboard/api"
+)
+
+// exit codes of every command
+const (
+	exitOK    = 0
+	exitError = 1 // the command failed, or validate found problems
+	exitUsage = 2 // bad command line
+)
+
+// command is one subcommand of the CLI
+type command struct {
+	name    string
+	summary string // one line, for the command list
+	help    string // more detail, for the command's --help
+	flags   func(fs *flag.FlagSet, config *Config, webhooks *webhookFlags)
+	run     func(config Config) error
+}
+
+// commands are listed by --help in this order
+func commands() []command {
+	return []command{
+		{
+			name:    "fetch",
+			summary: "Fetch GitHub activity into the store",
+			help: "Fetches the issues and pull requests of every repository updated in the last --workdays workdays,\n" +
+				"with their comments, events, commits and reviews, replacing what the store held.\n" +
+				"Also appends the day's aggregates to the history.",
+			flags: func(fs *flag.FlagSet, config *Config, _ *webhookFlags) {
+				storeFlags(fs, config)
+				windowFlags(fs, config)
+				githubFlags(fs, config)
+			},
+			run: fetch,
+		},
+		{
+			name:    "render",
+			summary: "Render the static site from the store",
+			help: "Writes the HTML pages, the JSON API and the static files to --output.\n" +
+				"With --notify, then posts a summary to webhooks.",
+			flags: func(fs *flag.FlagSet, config *Config, webhooks *webhookFlags) {
+				storeFlags(fs, config)
+				windowFlags(fs, config)
+				siteFlags(fs, config)
+				notifyFlags(fs, config, webhooks)
+				baseURLFlag(fs, config)
+			},
+			run: renderAndNotify,
+		},
+		{
+			name:    "serve",
+			summary: "Serve the rendered site",
+			help: "Serves --output. With --refresh, also fetches and renders periodically,\n" +
+				"and with --dev, renders and re-renders whenever the theme changes.",
+			flags: func(fs *flag.FlagSet, config *Config, _ *webhookFlags) {
+				storeFlags(fs, config)
+				windowFlags(fs, config)
+				siteFlags(fs, config)
+				serveFlags(fs, config)
+				githubFlags(fs, config)
+			},
+			run: serveOrDev,
+		},
+		{
+			name:    "digest",
+			summary: "Write a Markdown and plain text digest",
+			help:    "Writes digest.md and digest.txt to --output, summarizing the stored activity.",
+			flags: func(fs *flag.FlagSet, config *Config, _ *webhookFlags) {
+				storeFlags(fs, config)
+				windowFlags(fs, config)
+				siteFlags(fs, config)
+				digestFlags(fs, config)
+			},
+			run: digest,
+		},
+		{
+			name:    "validate",
+			summary: "Check the theme, templates, store and webhooks",
+			help:    "Reports every problem it finds and exits with status 1 if there were any.",
+			flags: func(fs *flag.FlagSet, config *Config, webhooks *webhookFlags) {
+				storeFlags(fs, config)
+				siteFlags(fs, config)
+				digestFlags(fs, config)
+				notifyFlags(fs, config, webhooks)
+			},
+			run: validate,
+		},
+		{
+			name:    "stats",
+			summary: "Print what the store holds for each repository",
+			flags: func(fs *flag.FlagSet, config *Config, _ *webhookFlags) {
+				storeFlags(fs, config)
+			},
+			run: stats,
+		},
+		{
+			name:    "version",
+			summary: "Print version information",
+			run: func(Config) error {
+				printVersion(os.Stdout)
+				return nil
+			},
+		},
+	}
+}
+
+func githubFlags(fs *flag.FlagSet, config *Config) {
+	baseURLFlag(fs, config)
+	fs.StringVar(&config.GitHubApp, "github-app", config.GitHubApp, "Authenticate as this GitHub App (ID or client ID) instead of with KOKKOS_DASHBOARD_TOKEN")
+	fs.StringVar(&config.GitHubAppInstall, "github-app-installation", config.GitHubAppInstall, "Installation ID of the GitHub App")
+	fs.StringVar(&config.GitHubAppKeyFile, "github-app-key", config.GitHubAppKeyFile, "PEM private key file of the GitHub App")
+	fs.StringVar(&config.GitHubGraphQLURL, "github-graphql-url", config.GitHubGraphQLURL, "GitHub GraphQL API, if not the one next to --github-base-url")
+	fs.StringVar(&config.GitHubAPIVersion, "github-api-version", config.GitHubAPIVersion, "GitHub REST API version to request")
+	fs.StringVar(&config.GitHubBackend, "github-backend", config.GitHubBackend, "GitHub API to fetch with: rest, or graphql for far fewer requests")
+	fs.StringVar(&config.GitHubRecordDir, "github-record", config.GitHubRecordDir, "Save GitHub responses as fixtures in this directory")
+	fs.StringVar(&config.GitHubReplayDir, "github-replay", config.GitHubReplayDir, "Answer GitHub requests from the fixtures in this directory instead of fetching")
+	fs.Func("branches", "Comma-separated branches, or patterns like release*, whose commits are fetched (default "+strings.Join(config.Branches, ",")+")", func(value string) error {
+		config.Branches = strings.Split(value, ",")
+		return nil
+	})
+}
+
+// baseURLFlag is the one GitHub flag render needs, for links
+func baseURLFlag(fs *flag.FlagSet, config *Config) {
+	fs.StringVar(&config.GitHubBaseURL, "github-base-url", config.GitHubBaseURL, "GitHub REST API to fetch from, https://HOST/api/v3 for GitHub Enterprise Server; pages link to the web interface next to it")
+}
+
+func storeFlags(fs *flag.FlagSet, config *Config) {
+	fs.StringVar(&config.Store, "store", config.Store, "Where fetch puts data for render: fs or sqlite")
+	fs.Func("data", "Directory of the fs store or file of the sqlite store (default data/ or data.sqlite)", func(value string) error {
+		config.FetchDir, config.SQLitePath = value, value
+		return nil
+	})
+	fs.StringVar(&config.HistoryDir, "history", config.HistoryDir, "Directory of the daily history")
+}
+
+func windowFlags(fs *flag.FlagSet, config *Config) {
+	fs.IntVar(&config.Workdays, "workdays", config.Workdays, "How many workdays of activity to cover")
+	fs.Func("now", "Pretend it is this RFC 3339 time, e.g. to render reproducibly (default the current time)", func(value string) error {
+		now, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return err
+		}
+		config.Clock = fixedClock(now)
+		return nil
+	})
+}
+
+func siteFlags(fs *flag.FlagSet, config *Config) {
+	fs.StringVar(&config.OutputDir, "output", config.OutputDir, "Directory the site is rendered to")
+	fs.StringVar(&config.SiteRoot, "site-root", config.SiteRoot, "Site root for render")
+	fs.StringVar(&config.ThemeDir, "theme-dir", config.ThemeDir, "Directory whose theme.json, templates/ and static/ files override the built-in ones (serve --dev defaults to .)")
+}
+
+func notifyFlags(fs *flag.FlagSet, config *Config, webhooks *webhookFlags) {
+	fs.Var(webhooks, "notify", "After render, post a summary to a webhook given as format=url (slack, matrix or discord). Repeatable")
+	fs.BoolVar(&config.NotifyDryRun, "notify-dry-run", config.NotifyDryRun, "Print webhook payloads instead of posting them")
+	fs.StringVar(&config.SiteURL, "site-url", config.SiteURL, "Public address of the site, e.g. https://kokkos.github.io/kokkos-dashboard/, which notifications link to")
+}
+
+func serveFlags(fs *flag.FlagSet, config *Config) {
+	fs.StringVar(&config.ListenAddr, "addr", config.ListenAddr, "Address to listen on")
+	fs.StringVar(&config.TLSCert, "tls-cert", config.TLSCert, "Certificate file; with --tls-key, serve uses HTTPS")
+	fs.StringVar(&config.TLSKey, "tls-key", config.TLSKey, "Private key file for --tls-cert")
+	fs.DurationVar(&config.RefreshInterval, "refresh", config.RefreshInterval, "Fetch and render again this often, e.g. 1h (0 disables)")
+	fs.BoolVar(&config.Dev, "dev", config.Dev, "Render, then re-render and reload pages when the theme changes")
+	fs.BoolVar(&config.DevWatchData, "dev-watch-data", config.DevWatchData, "With --dev, also re-render when fetched data changes")
+}
+
+func digestFlags(fs *flag.FlagSet, config *Config) {
+	fs.StringVar(&config.DigestTemplateDir, "digest-templates", config.DigestTemplateDir, "Directory with digest.md.tmpl and digest.txt.tmpl (default: the theme or built-in ones)")
+}
+
+func isHelp(arg string) bool {
+	return arg == "help" || arg == "-h" || arg == "-help" || arg == "--help"
+}
+
+// usage lists the commands
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: kokkos-dashboard <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, cmd := range commands() {
+		fmt.Fprintf(tw, "  %s\t%s\n", cmd.name, cmd.summary)
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Run "kokkos-dashboard <command> --help" for the flags of a command.`)
+	fmt.Fprintln(w, "The old --fetch, --render, --serve, --dev and --digest flags still work but are deprecated.")
+}
+
+// runCommand runs the command named by args[0] with the rest of args as its flags, and returns the exit code
+func runCommand(args []string) int {
+	if len(args) == 0 {
+		usage(os.Stderr)
+		return exitUsage
+	}
+	if isHelp(args[0]) {
+		if len(args) > 1 {
+			return runCommand([]string{args[1], "--help"})
+		}
+		usage(os.Stdout)
+		return exitOK
+	}
+
+	for _, cmd := range commands() {
+		if cmd.name == args[0] {
+			return cmd.execute(args[1:])
+		}
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
+	usage(os.Stderr)
+	return exitUsage
+}
+
+// execute parses the command's flags and runs it
+func (cmd command) execute(args []string) int {
+	config := defaultConfig()
+	var webhooks webhookFlags
+
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintf(w, "Usage: kokkos-dashboard %s [flags]\n\n%s\n", cmd.name, cmd.summary)
+		if cmd.help != "" {
+			fmt.Fprintf(w, "\n%s\n", cmd.help)
+		}
+		fmt.Fprintln(w, "\nFlags:")
+		fs.PrintDefaults()
+	}
+	if cmd.flags != nil {
+		cmd.flags(fs, &config, &webhooks)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(fs.Output(), "unexpected argument %q\n", fs.Arg(0))
+		fs.Usage()
+		return exitUsage
+	}
+	if err := config.check(); err != nil {
+		fmt.Fprintln(fs.Output(), err)
+		return exitUsage
+	}
+
+	if err := envWebhooks(&webhooks); err != nil {
+		log.Println(err)
+		return exitUsage
+	}
+	config.Webhooks = webhooks
+	config.Since = sinceWorkdays(config.Clock.Now(), config.Workdays)
+
+	stopReplay, err := startReplay(&config)
+	if err != nil {
+		log.Printf("%s error: %v", cmd.name, err)
+		return exitError
+	}
+	defer stopReplay()
+	if err := setupGitHubAuth(&config); err != nil {
+		log.Printf("%s error: %v", cmd.name, err)
+		return exitError
+	}
+
+	if err := cmd.run(config); err != nil {
+		log.Printf("%s error: %v", cmd.name, err)
+		return exitError
+	}
+	return exitOK
+}
+
+// renderAndNotify renders the site and then posts a summary to config.Webhooks, if any
+func renderAndNotify(config Config) error {
+	if err := render(config); err != nil {
+		return err
+	}
+	if len(config.Webhooks) == 0 {
+		return nil
+	}
+
+	log.Println("notify webhooks")
+	repoData, err := loadRepoData(config)
+	if err != nil {
+		return fmt.Errorf("notify: %w", err)
+	}
+	if err := notifyDigest(repoData, config); err != nil {
+		return fmt.Errorf("notify: %w", err)
+	}
+	return nil
+}
+
+// serveOrDev runs the dev server with config.Dev, otherwise the regular one
+func serveOrDev(config Config) error {
+	if config.Dev {
+		if config.ThemeDir == "" {
+			config.ThemeDir = "."
+		}
+		return dev(config, config.DevWatchData)
+	}
+	return serve(config, config.RefreshInterval)
+}
+
+// digest writes the digest of the stored data
+func digest(config Config) error {
+	repoData, err := loadRepoData(config)
+	if err != nil {
+		return err
+	}
+	return renderDigest(repoData, config)
+}
+
+// validate reports problems with everything a render reads
+func validate(config Config) error {
+	problems := 0
+	check := func(what string, err error) {
+		if err != nil {
+			problems++
+			fmt.Printf("FAIL %s: %v\n", what, err)
+		} else {
+			fmt.Printf("ok   %s\n", what)
+		}
+	}
+
+	_, err := loadTheme(config)
+	check("theme", err)
+
+	_, err = parseTemplates(config)
+	check("page templates", err)
+
+	for _, name := range []string{"digest.md", "digest.txt"} {
+		_, err := parseDigestTemplate(name, config)
+		check(name+" template", err)
+	}
+
+	st, err := openStore(config)
+	if err == nil {
+		_, err = st.ListRepos()
+		st.Close()
+	}
+	check(config.Store+" store", err)
+
+	for _, target := range config.Webhooks {
+		check("webhook "+target.Redacted(), target.Check())
+	}
+
+	if config.GitHubToken == "" && config.GitHubApp == "" {
+		fmt.Println("note neither KOKKOS_DASHBOARD_TOKEN nor a GitHub App is set, so fetch is limited to 60 requests an hour")
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("%d problem(s)", problems)
+	}
+	return nil
+}
+
+// stats prints the stored issues, pull requests and comments of each repository
+func stats(config Config) error {
+	st, err := openStore(config)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	repos, err := st.ListRepos()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "repository\tissues\topen\tPRs\topen\tcomments\t")
+	for _, repo := range repos {
+		issues, err := st.ListIssues(repo.Owner, repo.Name)
+		if err != nil {
+			return err
+		}
+
+		var nIssues, openIssues, nPRs, openPRs, comments int
+		for _, issue := range issues {
+			open := issue.State == "open"
+			if issue.PullRequest != nil {
+				nPRs++
+				if open {
+					openPRs++
+				}
+			} else {
+				nIssues++
+				if open {
+					openIssues++
+				}
+			}
+
+			stored, err := st.Comments(repo.Owner, repo.Name, issue.Number)
+			if err != nil {
+				return err
+			}
+			comments += len(stored)
+		}
+		fmt.Fprintf(tw, "%s/%s\t%d\t%d\t%d\t%d\t%d\t\n", repo.Owner, repo.Name, nIssues, openIssues, nPRs, openPRs, comments)
+	}
+	return tw.Flush()
+}
+
+// printVersion describes the build from the information the Go toolchain embeds
+func printVersion(w io.Writer) {
+	version, revision, modified, goVersion := "(devel)", "", false, ""
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if info.Main.Version != "" {
+			version = info.Main.Version
+		}
+		goVersion = info.GoVersion
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				revision = setting.Value
+			case "vcs.modified":
+				modified = setting.Value == "true"
+			}
+		}
+	}
+
+	fmt.Fprintf(w, "kokkos-dashboard %s\n", version)
+	if revision != "" {
+		if modified {
+			revision += " (modified)"
+		}
+		fmt.Fprintf(w, "revision %s\n", revision)
+	}
+	fmt.Fprintf(w, "api %s\n", api.Version)
+	fmt.Fprintf(w, "%s\n", strings.TrimSpace(goVersion))
+}
@@ -0,0 +1,18 @@
+package main
+
+import "time"
+
+// Clock tells the time that builds are stamped with and that activity windows end at
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is the real time
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// fixedClock is always the same time, which makes renders reproducible
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time { return time.Time(c) }
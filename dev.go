@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// how often --dev looks for changed files
+const devPollInterval = 500 * time.Millisecond
+
+// devReloadScript reloads the page when the dev server says so
+const devReloadScript = `<script>new EventSource("/_dev/events").onmessage = () => location.reload();</script>`
+
+// devServer re-renders when templates or static files change and tells open pages to reload
+type devServer struct {
+	config  Config
+	watched []string
+	ctx     context.Context
+
+	mu      sync.Mutex
+	err     error // of the last render
+	clients map[chan struct{}]bool
+}
+
+// fingerprint summarizes the files under the watched paths, so any edit changes it
+func (d *devServer) fingerprint() string {
+	var b bytes.Buffer
+	for _, root := range d.watched {
+		filepath.WalkDir(root, func(name string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if info, err := entry.Info(); err == nil {
+				fmt.Fprintf(&b, "%s %d %d\n", name, info.Size(), info.ModTime().UnixNano())
+			}
+			return nil
+		})
+	}
+	return b.String()
+}
+
+// rebuild renders the site and asks pages to reload, whether or not the render worked
+func (d *devServer) rebuild() {
+	log.Println("dev: render")
+	err := render(d.config)
+	if err != nil {
+		log.Printf("dev: render error: %v", err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.err = err
+	for client := range d.clients {
+		select {
+		case client <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// watch polls the watched paths and rebuilds when they change
+func (d *devServer) watch() {
+	last := d.fingerprint()
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-time.After(devPollInterval):
+		}
+
+		if current := d.fingerprint(); current != last {
+			last = current
+			d.rebuild()
+		}
+	}
+}
+
+// serveEvents is a server-sent events stream with a message for every rebuild
+func (d *devServer) serveEvents(w http.ResponseWriter, req *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	client := make(chan struct{}, 1)
+	d.mu.Lock()
+	d.clients[client] = true
+	d.mu.Unlock()
+	defer func() {
+		d.mu.Lock()
+		delete(d.clients, client)
+		d.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-d.ctx.Done():
+			return
+		case <-client:
+			fmt.Fprint(w, "data: reload\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// ServeHTTP serves the rendered site with the reload script added to every page,
+// or the render error if the last render failed
+func (d *devServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	d.mu.Lock()
+	renderErr := d.err
+	d.mu.Unlock()
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	if renderErr != nil {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "<!DOCTYPE html>\n<html><head><title>Render error</title></head><body>"+
+			"<h1>Render error</h1><pre>%s</pre>%s</body></html>\n",
+			html.EscapeString(renderErr.Error()), devReloadScript)
+		return
+	}
+
+	// serve uncompressed so the script can be added
+	req.Header.Del("Accept-Encoding")
+	req.Header.Del("If-None-Match")
+	req.Header.Del("If-Modified-Since")
+
+	rec := httptest.NewRecorder()
+	fileServer{
+		root:     func() string { return d.config.OutputDir },
+		siteRoot: d.config.SiteRoot,
+	}.ServeHTTP(rec, req)
+
+	body := rec.Body.Bytes()
+	if strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+		if i := bytes.LastIndex(body, []byte("</body>")); i >= 0 {
+			body = append(body[:i:i], append([]byte(devReloadScript), body[i:]...)...)
+		} else {
+			body = append(body, devReloadScript...)
+		}
+	}
+
+	for key, values := range rec.Header() {
+		w.Header()[key] = values
+	}
+	w.Header().Del("ETag")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(rec.Code)
+	w.Write(body)
+}
+
+// dev renders and serves the site, re-rendering whenever the theme's templates/, static/ and theme.json, or with watchData the store, change
+func dev(config Config, watchData bool) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	d := &devServer{
+		config: config,
+		watched: []string{
+			filepath.Join(config.ThemeDir, "templates"),
+			filepath.Join(config.ThemeDir, "static"),
+			filepath.Join(config.ThemeDir, "theme.json"),
+		},
+		ctx:     ctx,
+		clients: map[chan struct{}]bool{},
+	}
+	if watchData {
+		if config.Store == "sqlite" {
+			d.watched = append(d.watched, config.SQLitePath)
+		} else {
+			d.watched = append(d.watched, config.FetchDir)
+		}
+	}
+
+	d.rebuild()
+	go d.watch()
+
+	mux := http.NewServeMux()
+	mux.Handle("/", d)
+	mux.HandleFunc("/_dev/events", d.serveEvents)
+
+	log.Println("dev: watching", d.watched)
+	return listenAndServe(ctx, mux, config)
+}
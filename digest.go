@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"kokkos-dashboard/notify"
+	"kokkos-dashboard/ratelimit"
+)
+
+const (
+	// how many of the most commented issues are listed as hot
+	hotDiscussionCount = 5
+	// an issue needs at least this many new comments to be hot
+	hotDiscussionMinComments = 3
+)
+
+// Digest is what the digest templates are executed with
+type Digest struct {
+	Theme     Theme
+	Since     time.Time
+	BuildDate time.Time
+	SiteRoot  string
+	Repos     []RepoDigest
+}
+
+// RepoDigest is the activity in one repo since Digest.Since
+type RepoDigest struct {
+	Owner          string
+	Repo           string
+	NewPRs         []Issue
+	MergedPRs      []Issue
+	NewIssues      []Issue
+	ClosedIssues   []Issue
+	HotDiscussions []HotDiscussion
+}
+
+// HotDiscussion is an issue or pull request with many comments since Digest.Since
+type HotDiscussion struct {
+	Issue
+	NewComments int
+}
+
+// Empty is true if nothing happened in the repo
+func (r RepoDigest) Empty() bool {
+	return len(r.NewPRs)+len(r.MergedPRs)+len(r.NewIssues)+len(r.ClosedIssues)+len(r.HotDiscussions) == 0
+}
+
+// newComments counts the comments on issue created since a time
+func newComments(issue Issue, since time.Time) int {
+	n := 0
+	for _, comment := range issue.Comments {
+		if !comment.CreatedAt.Before(since) {
+			n++
+		}
+	}
+	return n
+}
+
+func after(t *time.Time, since time.Time) bool {
+	return t != nil && !t.Before(since)
+}
+
+// makeDigest groups the activity of each repo, in the same order as the site
+func makeDigest(repoData map[string]*RepoData, theme Theme, config Config, buildDate time.Time) Digest {
+	var repoKeys []string
+	for key := range repoData {
+		repoKeys = append(repoKeys, key)
+	}
+	sort.Strings(repoKeys)
+
+	digest := Digest{
+		Theme:     theme,
+		Since:     config.Since,
+		BuildDate: buildDate,
+		SiteRoot:  config.SiteRoot,
+	}
+
+	for _, key := range repoKeys {
+		repo := repoData[key]
+		rd := RepoDigest{Owner: repo.Owner, Repo: repo.Repo}
+
+		hot := []HotDiscussion{}
+		for _, issue := range repo.Issues {
+			isNew := !issue.CreatedAt.Before(config.Since)
+			if issue.PullRequest != nil {
+				if isNew {
+					rd.NewPRs = append(rd.NewPRs, issue)
+				}
+				if issue.PR != nil && issue.PR.Merged && after(issue.PR.MergedAt, config.Since) {
+					rd.MergedPRs = append(rd.MergedPRs, issue)
+				}
+			} else {
+				if isNew {
+					rd.NewIssues = append(rd.NewIssues, issue)
+				}
+				if issue.State == "closed" && after(issue.ClosedAt, config.Since) {
+					rd.ClosedIssues = append(rd.ClosedIssues, issue)
+				}
+			}
+			if n := newComments(issue, config.Since); n >= hotDiscussionMinComments {
+				hot = append(hot, HotDiscussion{Issue: issue, NewComments: n})
+			}
+		}
+
+		sort.SliceStable(hot, func(i, j int) bool {
+			return hot[i].NewComments > hot[j].NewComments
+		})
+		rd.HotDiscussions = hot[:min(len(hot), hotDiscussionCount)]
+
+		if !rd.Empty() {
+			digest.Repos = append(digest.Repos, rd)
+		}
+	}
+	return digest
+}
+
+// digestFuncs are available to the digest templates
+var digestFuncs = template.FuncMap{
+	"date": func(t time.Time) string {
+		return t.UTC().Format("2006-01-02 15:04 UTC")
+	},
+	"upper": strings.ToUpper,
+	"dict": func(kv ...any) map[string]any {
+		m := map[string]any{}
+		for i := 0; i+1 < len(kv); i += 2 {
+			m[fmt.Sprint(kv[i])] = kv[i+1]
+		}
+		return m
+	},
+}
+
+// parseDigestTemplate parses name+".tmpl" from config.DigestTemplateDir if set,
+// otherwise from the theme or built-in templates
+func parseDigestTemplate(name string, config Config) (*template.Template, error) {
+	tmpl := template.New(name + ".tmpl").Funcs(digestFuncs)
+	if config.DigestTemplateDir != "" {
+		return tmpl.ParseFiles(filepath.Join(config.DigestTemplateDir, tmpl.Name()))
+	}
+	text, err := readAsset(config, path.Join("templates", tmpl.Name()))
+	if err != nil {
+		return nil, err
+	}
+	return tmpl.Parse(string(text))
+}
+
+// renderDigest writes digest.md and digest.txt to the output directory.
+// The formats come from the digest.md.tmpl and digest.txt.tmpl templates.
+func renderDigest(repoData map[string]*RepoData, config Config) error {
+	theme, err := loadTheme(config)
+	if err != nil {
+		return err
+	}
+	digest := makeDigest(repoData, theme, config, config.Clock.Now())
+
+	if err := os.MkdirAll(config.OutputDir, 0755); err != nil {
+		return err
+	}
+
+	for _, name := range []string{"digest.md", "digest.txt"} {
+		tmpl, err := parseDigestTemplate(name, config)
+		if err != nil {
+			return fmt.Errorf("digest template: %w", err)
+		}
+
+		var b strings.Builder
+		if err := tmpl.Execute(&b, digest); err != nil {
+			return fmt.Errorf("digest template %s: %w", tmpl.Name(), err)
+		}
+
+		outputPath := filepath.Join(config.OutputDir, name)
+		log.Println("write digest to", outputPath)
+		if err := os.WriteFile(outputPath, []byte(b.String()), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// summarize turns a digest into the message posted to webhooks, linking to the site at siteURL if it is set
+func summarize(digest Digest, siteURL string) notify.Summary {
+	summary := notify.Summary{
+		Title: fmt.Sprintf("%s activity since %s", digest.Theme.Name, digest.Since.UTC().Format("2006-01-02 15:04 UTC")),
+		URL:   siteURL,
+	}
+
+	for _, repo := range digest.Repos {
+		section := notify.Section{Heading: repo.Owner + "/" + repo.Repo}
+		add := func(label string, issues []Issue) {
+			for _, issue := range issues {
+				section.Items = append(section.Items, notify.Item{
+					Text: fmt.Sprintf("%s #%d %s", label, issue.Number, issue.Title),
+					URL:  issue.HTMLURL,
+				})
+			}
+		}
+		add("New PR", repo.NewPRs)
+		add("Merged", repo.MergedPRs)
+		add("New issue", repo.NewIssues)
+		add("Closed", repo.ClosedIssues)
+		for _, issue := range repo.HotDiscussions {
+			section.Items = append(section.Items, notify.Item{
+				Text: fmt.Sprintf("Hot #%d %s (%d new comments)", issue.Number, issue.Title, issue.NewComments),
+				URL:  issue.HTMLURL,
+			})
+		}
+		summary.Sections = append(summary.Sections, section)
+	}
+	return summary
+}
+
+// notifyDigest posts a summary of the digest to config.Webhooks
+func notifyDigest(repoData map[string]*RepoData, config Config) error {
+	theme, err := loadTheme(config)
+	if err != nil {
+		return err
+	}
+	summary := summarize(makeDigest(repoData, theme, config, config.Clock.Now()), config.SiteURL)
+	client := ratelimit.NewRateLimitedClient(time.Second)
+	return notify.New(client, config.NotifyDryRun, os.Stdout).Send(config.Webhooks, summary)
+}
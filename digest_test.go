@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"kokkos-dashboard/github"
+)
+
+// commentedIssue is an issue with comments created at the given times
+func commentedIssue(number int, created ...time.Time) Issue {
+	issue := Issue{Issue: github.Issue{Number: number, CreatedAt: created[0].AddDate(0, -1, 0)}}
+	for _, t := range created {
+		issue.Comments = append(issue.Comments, &github.IssueComment{CreatedAt: t, UpdatedAt: t})
+	}
+	return issue
+}
+
+func TestHotDiscussionsCountNewComments(t *testing.T) {
+	since := time.Date(2025, 6, 2, 12, 0, 0, 0, time.UTC)
+	old, recent := since.Add(-time.Hour), since.Add(time.Hour)
+
+	repo := &RepoData{Owner: "kokkos", Repo: "kokkos", Issues: []Issue{
+		commentedIssue(1, old, old, old, old, old, recent),        // long thread, quiet since
+		commentedIssue(2, recent, recent, recent),                 // just enough
+		commentedIssue(3, old, recent, recent, recent, recent),    // most new comments
+		commentedIssue(4, old, old, old, old, old, old, old, old), // longest thread, all old
+		commentedIssue(5, recent, recent),
+	}}
+	config := defaultConfig()
+	config.Since = since
+
+	digest := makeDigest(map[string]*RepoData{"kokkos/kokkos": repo}, Theme{}, config, since.Add(48*time.Hour))
+	if len(digest.Repos) != 1 {
+		t.Fatalf("%d repos in the digest, want 1", len(digest.Repos))
+	}
+	var got []string
+	for _, hot := range digest.Repos[0].HotDiscussions {
+		got = append(got, fmt.Sprintf("#%d:%d", hot.Number, hot.NewComments))
+	}
+	if fmt.Sprint(got) != "[#3:4 #2:3]" {
+		t.Errorf("hot discussions %v, want [#3:4 #2:3]", got)
+	}
+}
+
+func TestSummarizeLinksToSite(t *testing.T) {
+	since := time.Date(2025, 6, 2, 12, 0, 0, 0, time.UTC)
+	digest := Digest{Theme: Theme{Name: "Kokkos"}, Since: since}
+	if summary := summarize(digest, "https://kokkos.github.io/kokkos-dashboard/"); summary.URL != "https://kokkos.github.io/kokkos-dashboard/" {
+		t.Errorf("summary links to %q", summary.URL)
+	}
+	if summary := summarize(digest, ""); summary.URL != "" {
+		t.Errorf("summary without --site-url links to %q", summary.URL)
+	}
+
+	config := defaultConfig()
+	for siteURL, ok := range map[string]bool{
+		"https://kokkos.github.io/kokkos-dashboard/": true,
+		"/kokkos-dashboard/":                         false,
+		"kokkos.github.io":                           false,
+	} {
+		config.SiteURL = siteURL
+		if err := config.check(); (err == nil) != ok {
+			t.Errorf("--site-url=%s: error %v", siteURL, err)
+		}
+	}
+}
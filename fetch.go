@@ -1,109 +1,440 @@
 package main
 
 import (
-	"encoding/json"
-	"fmt"
 	"log"
-	"os"
-	"path/filepath"
+	"path"
+	"slices"
+	"sort"
+	"time"
 
+	"kokkos-dashboard/fixture"
 	"kokkos-dashboard/github"
+	"kokkos-dashboard/history"
+	"kokkos-dashboard/metrics"
+	"kokkos-dashboard/store"
 )
 
-func marshalAndWrite(v any, name string, perm os.FileMode) error {
-	err := os.MkdirAll(filepath.Dir(name), perm)
-	if err != nil {
-		return err
+var (
+	fetchDuration = metrics.NewGauge("kokkos_dashboard_fetch_duration_seconds",
+		"Duration of the last fetch")
+	fetchedItems = metrics.NewCounter("kokkos_dashboard_fetched_items_total",
+		"Items retrieved from GitHub, by kind", "kind")
+)
+
+// mostUpvotedIssues is how many of a repo's most upvoted open issues are fetched
+const mostUpvotedIssues = 5
+
+// workflowEvents trigger the workflow runs on the default branch that are fetched:
+// post-merge and nightly CI
+var workflowEvents = []string{"push", "schedule"}
+
+// githubBackend is what fetch needs from GitHub.
+// github.Client implements it with the REST API and github.GraphQLClient with the GraphQL API.
+type githubBackend interface {
+	GetRecentIssueDetails(owner, repo string, since time.Time) ([]github.IssueDetails, error)
+	GetIssue(owner, repo string, number int) (*github.Issue, error)
+	GetIssueDetails(owner, repo string, issue github.Issue, since time.Time) (*github.IssueDetails, error)
+	CountIssues(query string) (int, error)
+	GetReleases(owner, repo string, since time.Time) ([]github.Release, error)
+	GetTags(owner, repo string) ([]github.Tag, error)
+	GetMostUpvotedIssues(owner, repo string, n int) ([]github.Issue, error)
+	SetMinInterval(interval time.Duration)
+}
+
+// githubOptions configures clients of config.GitHubBaseURL,
+// recording the responses if config.GitHubRecordDir is set
+func githubOptions(config Config) github.Options {
+	opts := github.Options{
+		Auth:       config.GitHubAuth,
+		BaseURL:    config.GitHubBaseURL,
+		GraphQLURL: config.GitHubGraphQLURL,
+		APIVersion: config.GitHubAPIVersion,
+	}
+	if config.GitHubRecordDir != "" {
+		opts.Transport = fixture.NewRecorder(config.GitHubRecordDir, nil)
 	}
+	return opts
+}
 
-	data, err := json.MarshalIndent(v, "", "  ")
-	if err != nil {
-		return err
+// newGitHubClient talks to GitHub with the backend in config.GitHubBackend
+func newGitHubClient(config Config) githubBackend {
+	var client githubBackend
+	if config.GitHubBackend == "graphql" {
+		client = github.NewGraphQLClient(githubOptions(config))
+	} else {
+		client = github.NewClientWithOptions(githubOptions(config))
+	}
+	if config.GitHubReplayDir != "" {
+		// fixtures have no rate limit
+		client.SetMinInterval(0)
 	}
+	return client
+}
 
-	log.Printf("write %d to %s", len(data), name)
-	err = os.WriteFile(name, data, 0755)
-	if err != nil {
-		return err
+// newDiscussionsClient talks to the GraphQL API, the only one that serves discussions.
+// It is nil without credentials, which the GraphQL API requires.
+func newDiscussionsClient(config Config) *github.GraphQLClient {
+	if config.GitHubReplayDir != "" {
+		client := github.NewGraphQLClient(githubOptions(config))
+		client.SetMinInterval(0)
+		return client
 	}
-	return nil
+	if config.GitHubAuth == nil {
+		return nil
+	}
+	return github.NewGraphQLClient(githubOptions(config))
+}
+
+// newRESTClient talks to the REST API, for what fetch only gets from there:
+// Actions workflow runs and branch commits with their pull requests
+func newRESTClient(config Config) *github.Client {
+	client := github.NewClientWithOptions(githubOptions(config))
+	if config.GitHubReplayDir != "" {
+		client.SetMinInterval(0)
+	}
+	return client
 }
 
 func fetch(config Config) error {
-	client := github.NewClient(config.GitHubToken)
+	start := time.Now()
+	defer func() {
+		fetchDuration.Set(time.Since(start).Seconds())
+	}()
+
+	client := newGitHubClient(config)
+	restClient := newRESTClient(config)
+	discussionsClient := newDiscussionsClient(config)
+	if discussionsClient == nil {
+		log.Println("not fetching discussions: the GraphQL API needs credentials")
+	}
+	defer credentialUsage(config)()
+
+	st, err := openStore(config)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
 
 	log.Println("fetching since", config.Since)
 
-	log.Println("remove", config.FetchDir)
-	os.RemoveAll(config.FetchDir)
+	if err := st.Reset(); err != nil {
+		return err
+	}
 
 	// retrieve data
 	for _, repo := range config.Repositories {
 
-		repoOutputDir := filepath.Join(config.FetchDir, repo.Owner, repo.Name)
-
 		log.Printf("Fetching issues for %s/%s...", repo.Owner, repo.Name)
-		issues, err := client.GetRecentIssues(repo.Owner, repo.Name, config.Since)
+		all, err := client.GetRecentIssueDetails(repo.Owner, repo.Name, config.Since)
+		if err != nil {
+			return err
+		}
+		issues := []github.Issue{}
+		for _, details := range all {
+			issues = append(issues, details.Issue)
+		}
+
+		// record yesterday's and today's activity, which the window always covers
+		now := config.Clock.Now()
+		tally := history.NewTally(now.AddDate(0, 0, -1), now)
+
+		log.Printf("Fetching releases for %s/%s...", repo.Owner, repo.Name)
+		releases, err := client.GetReleases(repo.Owner, repo.Name, config.Since)
+		if err != nil {
+			return err
+		}
+
+		discussions := []github.Discussion{}
+		if discussionsClient != nil {
+			log.Printf("Fetching discussions for %s/%s...", repo.Owner, repo.Name)
+			if discussions, err = discussionsClient.GetRecentDiscussions(repo.Owner, repo.Name, config.Since); err != nil {
+				return err
+			}
+		}
+
+		log.Printf("Fetching workflow runs for %s/%s...", repo.Owner, repo.Name)
+		runs, err := getWorkflowRuns(restClient, repo.Owner, repo.Name, config.Since)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("Fetching branch commits for %s/%s...", repo.Owner, repo.Name)
+		commits, err := getBranchCommits(restClient, repo.Owner, repo.Name, config.Branches, config.Since)
 		if err != nil {
 			return err
 		}
 
 		// skip repo with no activity
-		if len(issues) == 0 {
+		if len(issues) == 0 && len(releases) == 0 && len(discussions) == 0 && !ranSince(runs, config.Since) && len(commits) == 0 {
+			if err := recordHistory(client, config, repo.Owner, repo.Name, tally); err != nil {
+				return err
+			}
 			continue
 		}
 
-		if err := marshalAndWrite(issues, filepath.Join(repoOutputDir, "issues.json"), 0755); err != nil {
+		if err := putReleases(client, st, repo.Owner, repo.Name, releases); err != nil {
 			return err
 		}
 
-		issuesOutputDir := filepath.Join(repoOutputDir, "issues")
-		for _, issue := range issues {
+		if err := putDiscussions(st, repo.Owner, repo.Name, discussions, tally); err != nil {
+			return err
+		}
 
-			issueOutputDir := filepath.Join(issuesOutputDir, fmt.Sprintf("%d", issue.Number))
+		fetchedItems.Add(float64(len(runs)), "workflow_runs")
+		if err := st.PutWorkflowRuns(repo.Owner, repo.Name, runs); err != nil {
+			return err
+		}
 
-			comments, err := client.GetIssueComments(repo.Owner, repo.Name, issue.Number, config.Since)
-			if err != nil {
-				return err
-			}
-			if err := marshalAndWrite(comments, filepath.Join(issueOutputDir, "comments.json"), 0755); err != nil {
-				return err
-			}
+		if err := putBranchCommits(st, repo.Owner, repo.Name, commits, tally); err != nil {
+			return err
+		}
 
-			events, err := client.GetIssueEvents(repo.Owner, repo.Name, issue.Number)
-			if err != nil {
-				return err
-			}
-			if err := marshalAndWrite(events, filepath.Join(issueOutputDir, "events.json"), 0755); err != nil {
+		fetchedItems.Add(float64(len(issues)), "issues")
+		if err := st.PutIssues(repo.Owner, repo.Name, issues); err != nil {
+			return err
+		}
+
+		log.Printf("Fetching most upvoted issues for %s/%s...", repo.Owner, repo.Name)
+		upvoted, err := client.GetMostUpvotedIssues(repo.Owner, repo.Name, mostUpvotedIssues)
+		if err != nil {
+			return err
+		}
+		if err := st.PutMostUpvoted(repo.Owner, repo.Name, upvoted); err != nil {
+			return err
+		}
+
+		for _, details := range all {
+			if err := putIssueDetails(st, repo.Owner, repo.Name, details, tally); err != nil {
 				return err
 			}
+		}
 
-			if issue.PullRequest != nil {
-				commits, err := client.GetPullRequestCommits(repo.Owner, repo.Name, issue.Number)
-				if err != nil {
-					return err
-				}
-				if err := marshalAndWrite(commits, filepath.Join(issueOutputDir, "commits.json"), 0755); err != nil {
-					return err
-				}
+		if err := recordHistory(client, config, repo.Owner, repo.Name, tally); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-				pr, err := client.GetPullRequest(repo.Owner, repo.Name, issue.Number)
-				if err != nil {
-					return err
-				}
-				if err := marshalAndWrite(pr, filepath.Join(issueOutputDir, "pr.json"), 0755); err != nil {
-					return err
-				}
+// putReleases stores releases and the tags they point to
+func putReleases(client githubBackend, st store.Store, owner, repo string, releases []github.Release) error {
+	fetchedItems.Add(float64(len(releases)), "releases")
+	if err := st.PutReleases(owner, repo, releases); err != nil {
+		return err
+	}
+	if len(releases) == 0 {
+		return nil
+	}
+
+	tags, err := client.GetTags(owner, repo)
+	if err != nil {
+		return err
+	}
+	return st.PutTags(owner, repo, tags)
+}
+
+// getWorkflowRuns retrieves the runs on the default branch triggered by workflowEvents, newest first
+func getWorkflowRuns(client *github.Client, owner, repo string, since time.Time) ([]github.WorkflowRun, error) {
+	branch, err := client.GetDefaultBranch(owner, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	runs := []github.WorkflowRun{}
+	for _, event := range workflowEvents {
+		eventRuns, err := client.GetWorkflowRuns(owner, repo, branch, event, since)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, eventRuns...)
+	}
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].CreatedAt.After(runs[j].CreatedAt)
+	})
+	return runs, nil
+}
+
+// getBranchCommits retrieves the commits since a time on the branches matching patterns,
+// with the merged pull requests that brought each one there
+func getBranchCommits(client *github.Client, owner, repo string, patterns []string, since time.Time) ([]github.BranchCommit, error) {
+	branches, err := client.GetBranches(owner, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	commits := []github.BranchCommit{}
+	prs := map[string][]int{} // by SHA, as a commit can be on several branches
+	for _, branch := range branches {
+		if !slices.ContainsFunc(patterns, func(pattern string) bool {
+			matched, _ := path.Match(pattern, branch.Name)
+			return matched
+		}) {
+			continue
+		}
 
-				reviews, err := client.GetPullRequestReviews(repo.Owner, repo.Name, issue.Number)
+		branchCommits, err := client.GetBranchCommits(owner, repo, branch.Name, since)
+		if err != nil {
+			return nil, err
+		}
+		for _, commit := range branchCommits {
+			numbers, ok := prs[commit.SHA]
+			if !ok {
+				pulls, err := client.GetCommitPullRequests(owner, repo, commit.SHA)
 				if err != nil {
-					return err
+					return nil, err
 				}
-				if err := marshalAndWrite(reviews, filepath.Join(issueOutputDir, "reviews.json"), 0755); err != nil {
-					return err
+				numbers = []int{}
+				for _, pr := range pulls {
+					if pr.MergedAt != nil {
+						numbers = append(numbers, pr.Number)
+					}
 				}
+				prs[commit.SHA] = numbers
+			}
+			commits = append(commits, github.BranchCommit{
+				PullRequestCommit: commit,
+				Branch:            branch.Name,
+				PullRequests:      numbers,
+			})
+		}
+	}
+	return commits, nil
+}
+
+// putBranchCommits stores branch commits and tallies their authors
+func putBranchCommits(st store.Store, owner, repo string, commits []github.BranchCommit, tally *history.Tally) error {
+	fetchedItems.Add(float64(len(commits)), "branch_commits")
+	for _, commit := range commits {
+		if commit.Author != nil {
+			tally.Contributor(commit.Commit.Author.Date, commit.Author.Login)
+		}
+	}
+	return st.PutBranchCommits(owner, repo, commits)
+}
+
+// ranSince is true if any run was created since a time
+func ranSince(runs []github.WorkflowRun, since time.Time) bool {
+	for _, run := range runs {
+		if !run.CreatedAt.Before(since) {
+			return true
+		}
+	}
+	return false
+}
+
+// putDiscussions stores discussions and tallies their participants
+func putDiscussions(st store.Store, owner, repo string, discussions []github.Discussion, tally *history.Tally) error {
+	fetchedItems.Add(float64(len(discussions)), "discussions")
+	for _, discussion := range discussions {
+		if discussion.User != nil {
+			tally.Contributor(discussion.CreatedAt, discussion.User.Login)
+		}
+		for _, comment := range discussion.Comments {
+			if comment.User != nil {
+				tally.Contributor(comment.CreatedAt, comment.User.Login)
+			}
+		}
+	}
+	return st.PutDiscussions(owner, repo, discussions)
+}
+
+// putIssueDetails puts everything attached to an issue in the store and tallies the activity
+func putIssueDetails(st store.Store, owner, repo string, details github.IssueDetails, tally *history.Tally) error {
+	issue := details.Issue
+	tally.Contributor(issue.CreatedAt, issue.User.Login)
+	if issue.PullRequest == nil {
+		tally.NewIssue(issue.CreatedAt)
+	}
+
+	for _, comment := range details.Comments {
+		tally.Contributor(comment.CreatedAt, comment.User.Login)
+	}
+	fetchedItems.Add(float64(len(details.Comments)), "comments")
+	if err := st.PutComments(owner, repo, issue.Number, details.Comments); err != nil {
+		return err
+	}
+
+	fetchedItems.Add(float64(len(details.Events)), "events")
+	if err := st.PutEvents(owner, repo, issue.Number, details.Events); err != nil {
+		return err
+	}
+
+	if issue.PullRequest != nil {
+		fetchedItems.Add(float64(len(details.Commits)), "commits")
+		if err := st.PutCommits(owner, repo, issue.Number, details.Commits); err != nil {
+			return err
+		}
+		for _, commit := range details.Commits {
+			if commit.Author != nil {
+				tally.Contributor(commit.Commit.Author.Date, commit.Author.Login)
+			}
+		}
+
+		if details.PR != nil {
+			fetchedItems.Inc("pull_requests")
+			if err := st.PutPullRequest(owner, repo, details.PR); err != nil {
+				return err
+			}
+			if details.PR.MergedAt != nil {
+				tally.MergedPR(*details.PR.MergedAt)
+			}
+		}
+
+		fetchedItems.Add(float64(len(details.Reviews)), "reviews")
+		if err := st.PutReviews(owner, repo, issue.Number, details.Reviews); err != nil {
+			return err
+		}
+		for _, review := range details.Reviews {
+			if review.User != nil && review.SubmittedAt != nil {
+				tally.Contributor(*review.SubmittedAt, review.User.Login)
 			}
 		}
 	}
 	return nil
 }
+
+// recordHistory appends the tallied days to the repo's history log, except those it already ends with.
+// Open counts are only known for today, so earlier days keep whatever a previous run recorded.
+func recordHistory(client githubBackend, config Config, owner, repo string, tally *history.Tally) error {
+	openIssues, err := client.CountIssues(github.OpenIssuesQuery(owner, repo))
+	if err != nil {
+		return err
+	}
+	openPRs, err := client.CountIssues(github.OpenPullRequestsQuery(owner, repo))
+	if err != nil {
+		return err
+	}
+
+	previous, err := history.Load(config.HistoryDir, owner, repo)
+	if err != nil {
+		return err
+	}
+	recorded := map[string]history.Snapshot{}
+	for _, s := range previous {
+		recorded[s.Date] = s
+	}
+
+	var changed []history.Snapshot
+	snapshots := tally.Snapshots()
+	for i, s := range snapshots {
+		last, ok := recorded[s.Date]
+		if i == len(snapshots)-1 {
+			s.OpenIssues = openIssues
+			s.OpenPRs = openPRs
+		} else if ok {
+			s.OpenIssues = last.OpenIssues
+			s.OpenPRs = last.OpenPRs
+		}
+		// the log would grow with every run otherwise
+		if !ok || s != last {
+			changed = append(changed, s)
+		}
+	}
+	if len(changed) == 0 {
+		log.Printf("history for %s/%s is unchanged", owner, repo)
+		return nil
+	}
+
+	log.Printf("record %d days of history for %s/%s to %s", len(changed), owner, repo, config.HistoryDir)
+	return history.Append(config.HistoryDir, owner, repo, changed...)
+}
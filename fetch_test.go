@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"kokkos-dashboard/history"
+)
+
+// replayConfig fetches kokkos/kokkos from the fixtures in testdata/fixtures into a temporary
+// store, the way --github-replay does, at the time they were recorded
+func replayConfig(t *testing.T) Config {
+	t.Helper()
+	dir := t.TempDir()
+	config := defaultConfig()
+	config.Repositories = config.Repositories[:1]
+	config.GitHubToken = ""
+	config.GitHubReplayDir = filepath.Join("testdata", "fixtures")
+	config.FetchDir = filepath.Join(dir, "data")
+	config.HistoryDir = filepath.Join(dir, "history")
+	config.OutputDir = filepath.Join(dir, "public")
+	config.Clock = fixedClock(time.Date(2025, 6, 4, 12, 0, 0, 0, time.UTC))
+	config.Since = sinceWorkdays(config.Clock.Now(), config.Workdays)
+
+	stop, err := startReplay(&config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(stop)
+	if err := setupGitHubAuth(&config); err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+func TestFetchRenderReplay(t *testing.T) {
+	config := replayConfig(t)
+	if err := fetch(config); err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if err := render(config); err != nil {
+		t.Fatalf("render: %v", err)
+	}
+
+	page, err := os.ReadFile(filepath.Join(config.OutputDir, "kokkos", "kokkos", "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Add SIMD reductions",             // pull request
+		"Kokkos 4.6.01",                   // release
+		"Roadmap for the SYCL backend",    // discussion, from the GraphQL API
+		"Nightly",                         // CI health
+		"release-4.6",                     // branch activity
+		"Merge pull request #98 from car", // a commit that came with a pull request
+	} {
+		if !strings.Contains(string(page), want) {
+			t.Errorf("repo page lacks %q", want)
+		}
+	}
+
+	index, err := os.ReadFile(filepath.Join(config.OutputDir, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(index), "kokkos/kokkos") {
+		t.Errorf("index lacks kokkos/kokkos")
+	}
+
+	var api struct {
+		Issues []struct {
+			Number int `json:"number"`
+		} `json:"issues"`
+	}
+	data, err := os.ReadFile(filepath.Join(config.OutputDir, "api", "v1", "kokkos", "kokkos.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &api); err != nil {
+		t.Fatal(err)
+	}
+	if len(api.Issues) != 2 {
+		t.Errorf("API has %d issues, want 2", len(api.Issues))
+	}
+
+	snapshots, err := history.Load(config.HistoryDir, "kokkos", "kokkos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) == 0 {
+		t.Fatal("no history recorded")
+	}
+	// open counts are only known for today
+	if got := snapshots[len(snapshots)-1]; got.OpenIssues != 42 || got.OpenPRs != 17 {
+		t.Errorf("history has %d open issues and %d open pull requests, want 42 and 17", got.OpenIssues, got.OpenPRs)
+	}
+}
+
+func TestFetchAgainKeepsHistory(t *testing.T) {
+	config := replayConfig(t)
+	if err := fetch(config); err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	first, err := os.ReadFile(history.Path(config.HistoryDir, "kokkos", "kokkos"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// nothing changed on GitHub, so nothing is appended
+	if err := fetch(config); err != nil {
+		t.Fatalf("fetch again: %v", err)
+	}
+	again, err := os.ReadFile(history.Path(config.HistoryDir, "kokkos", "kokkos"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != string(first) {
+		t.Errorf("the second fetch appended\n%s", strings.TrimPrefix(string(again), string(first)))
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// files with these extensions get .br and .gz siblings during render
+var compressible = map[string]bool{
+	".html": true,
+	".css":  true,
+	".js":   true,
+	".json": true,
+	".svg":  true,
+	".txt":  true,
+	".md":   true,
+}
+
+// encodings we serve precompressed, in order of preference
+var encodings = []struct {
+	name string // Content-Encoding
+	ext  string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// compressFile writes name.br and name.gz next to name
+func compressFile(name string) error {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	write := func(ext string, newWriter func(io.Writer) io.WriteCloser) error {
+		f, err := os.Create(name + ext)
+		if err != nil {
+			return err
+		}
+		w := newWriter(f)
+		if _, err := w.Write(data); err != nil {
+			f.Close()
+			return err
+		}
+		if err := w.Close(); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+
+	if err := write(".gz", func(w io.Writer) io.WriteCloser {
+		zw, _ := gzip.NewWriterLevel(w, gzip.BestCompression)
+		return zw
+	}); err != nil {
+		return err
+	}
+	return write(".br", func(w io.Writer) io.WriteCloser {
+		return brotli.NewWriterLevel(w, brotli.BestCompression)
+	})
+}
+
+// precompress compresses every compressible file under dir
+func precompress(dir string) error {
+	log.Println("precompress", dir)
+	return filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !compressible[filepath.Ext(name)] {
+			return nil
+		}
+		return compressFile(name)
+	})
+}
+
+// fileServer serves a rendered site from root.
+// Requests must be under siteRoot, precompressed variants are used when the client accepts them,
+// and responses carry ETag and Cache-Control headers.
+type fileServer struct {
+	root     func() string
+	siteRoot string
+}
+
+func (s fileServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	siteRoot := "/" + strings.Trim(s.siteRoot, "/")
+	if siteRoot != "/" {
+		siteRoot += "/"
+	}
+
+	if req.URL.Path+"/" == siteRoot || (req.URL.Path == "/" && siteRoot != "/") {
+		http.Redirect(w, req, siteRoot, http.StatusMovedPermanently)
+		return
+	}
+	rel, ok := strings.CutPrefix(req.URL.Path, siteRoot)
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+
+	// path.Clean of a rooted path cannot escape the root
+	rel = strings.TrimPrefix(path.Clean("/"+rel), "/")
+	name := filepath.Join(s.root(), filepath.FromSlash(rel))
+
+	info, err := os.Stat(name)
+	if err != nil {
+		http.NotFound(w, req)
+		return
+	}
+	if info.IsDir() {
+		if !strings.HasSuffix(req.URL.Path, "/") {
+			http.Redirect(w, req, req.URL.Path+"/", http.StatusMovedPermanently)
+			return
+		}
+		name = filepath.Join(name, "index.html")
+		if info, err = os.Stat(name); err != nil {
+			http.NotFound(w, req)
+			return
+		}
+	}
+
+	ext := filepath.Ext(name)
+	if contentType := mime.TypeByExtension(ext); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+
+	// pages change with every render, assets rarely do
+	if strings.HasPrefix(rel, "static/") {
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+
+	servedName, servedInfo := name, info
+	if compressible[ext] {
+		w.Header().Add("Vary", "Accept-Encoding")
+		accepted := req.Header.Get("Accept-Encoding")
+		for _, enc := range encodings {
+			if !acceptsEncoding(accepted, enc.name) {
+				continue
+			}
+			// a variant older than the file is stale
+			if encInfo, err := os.Stat(name + enc.ext); err == nil && !encInfo.ModTime().Before(info.ModTime()) {
+				servedName, servedInfo = name+enc.ext, encInfo
+				w.Header().Set("Content-Encoding", enc.name)
+				break
+			}
+		}
+	}
+
+	f, err := os.Open(servedName)
+	if err != nil {
+		http.NotFound(w, req)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("ETag", fmt.Sprintf(`"%x-%x%s"`, servedInfo.ModTime().UnixNano(), servedInfo.Size(), filepath.Ext(servedName)))
+	http.ServeContent(w, req, name, servedInfo.ModTime(), f)
+}
+
+// acceptsEncoding reports whether an Accept-Encoding header allows coding
+func acceptsEncoding(header, coding string) bool {
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(name), coding) {
+			continue
+		}
+		q := strings.ReplaceAll(params, " ", "")
+		return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+	}
+	return false
+}
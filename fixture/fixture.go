@@ -0,0 +1,244 @@
+// Package fixture records HTTP responses to files and serves them back,
+// so code that talks to the GitHub API can run offline.
+package fixture
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoredParams change from run to run, so they are left out when matching requests to fixtures.
+// They are query parameters or, in JSON request bodies like those of GraphQL, variables.
+var IgnoredParams = []string{"since"}
+
+// recordedHeaders are the response headers worth keeping; the rest are noise or secrets
+var recordedHeaders = []string{
+	"Content-Type",
+	"Link",
+	"Retry-After",
+	"X-RateLimit-Limit",
+	"X-RateLimit-Remaining",
+	"X-RateLimit-Reset",
+}
+
+// Fixture is one recorded request and its response
+type Fixture struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`               // path and query, without the host
+	Request json.RawMessage   `json:"request,omitempty"` // JSON request body, if any
+	Status  int               `json:"status"`
+	Header  map[string]string `json:"header,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"` // if the response was JSON
+	Text    string            `json:"text,omitempty"` // otherwise
+}
+
+// Key identifies the requests a fixture answers: the method, path, query and a hash of the body, without IgnoredParams
+func Key(method string, u *url.URL, body []byte) string {
+	query := u.Query()
+	for _, param := range IgnoredParams {
+		query.Del(param)
+	}
+	key := method + " " + u.EscapedPath()
+	if encoded := query.Encode(); encoded != "" { // Encode sorts by name
+		key += "?" + encoded
+	}
+	if len(body) > 0 {
+		key += fmt.Sprintf(" %x", sha256.Sum256(withoutIgnoredVariables(body)))
+	}
+	return key
+}
+
+// withoutIgnoredVariables removes IgnoredParams from the variables of a JSON body, in a canonical encoding
+func withoutIgnoredVariables(body []byte) []byte {
+	var request map[string]any
+	if err := json.Unmarshal(body, &request); err != nil {
+		return body
+	}
+	if variables, ok := request["variables"].(map[string]any); ok {
+		for _, param := range IgnoredParams {
+			delete(variables, param)
+		}
+	}
+	canonical, err := json.Marshal(request) // sorts object keys
+	if err != nil {
+		return body
+	}
+	return canonical
+}
+
+// FileName is where the fixture for key is stored: readable, and made unique by a hash
+func FileName(key string) string {
+	method, target, _ := strings.Cut(key, " ")
+	path, _, _ := strings.Cut(target, "?")
+	path, _, _ = strings.Cut(path, " ")
+	readable := strings.NewReplacer("/", "_", "%", "_", ".", "_").Replace(strings.Trim(path, "/"))
+	sum := sha256.Sum256([]byte(key))
+	return fmt.Sprintf("%s_%s-%x.json", method, readable, sum[:4])
+}
+
+// Recorder is an http.RoundTripper that saves every response it passes on as a fixture in Dir
+type Recorder struct {
+	Dir       string
+	Transport http.RoundTripper // http.DefaultTransport if nil
+}
+
+// NewRecorder records the responses of transport (http.DefaultTransport if nil) into dir
+func NewRecorder(dir string, transport http.RoundTripper) *Recorder {
+	return &Recorder{Dir: dir, Transport: transport}
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	var requestBody []byte
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		requestBody, err = io.ReadAll(body)
+		body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	f := Fixture{
+		Method: req.Method,
+		URL:    req.URL.RequestURI(),
+		Status: resp.StatusCode,
+		Header: map[string]string{},
+	}
+	if len(requestBody) > 0 && json.Valid(requestBody) {
+		f.Request = requestBody
+	}
+	for _, name := range recordedHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			f.Header[name] = value
+		}
+	}
+	if json.Valid(body) {
+		f.Body = body
+	} else {
+		f.Text = string(body)
+	}
+
+	if err := Save(r.Dir, f); err != nil {
+		log.Printf("fixture: save %s %s: %v", f.Method, f.URL, err)
+	}
+	return resp, nil
+}
+
+// Save writes f into dir, replacing an earlier fixture for the same request
+func Save(dir string, f Fixture) error {
+	u, err := url.Parse(f.URL)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	// indented, bodies included, so fixtures diff well
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(f); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, FileName(Key(f.Method, u, f.Request))), b.Bytes(), 0644)
+}
+
+// Load reads every fixture in dir, keyed by Key
+func Load(dir string) (map[string]Fixture, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	fixtures := map[string]Fixture{}
+	for _, name := range names {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		var f Fixture
+		if err := json.Unmarshal(data, &f); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		u, err := url.Parse(f.URL)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		fixtures[Key(f.Method, u, f.Request)] = f
+	}
+	return fixtures, nil
+}
+
+// Handler answers requests with the fixtures, and with 404 Not Found for requests nothing was recorded for
+func Handler(fixtures map[string]Fixture) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		key := Key(req.Method, req.URL, body)
+		f, ok := fixtures[key]
+		if !ok {
+			log.Printf("fixture: no fixture for %s", key)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"message": "Not Found", "fixture": %q}`, key)
+			return
+		}
+
+		for name, value := range f.Header {
+			w.Header().Set(name, value)
+		}
+		w.WriteHeader(f.Status)
+		if f.Body != nil {
+			w.Write(f.Body)
+		} else {
+			io.WriteString(w, f.Text)
+		}
+	})
+}
+
+// NewServer serves the fixtures in dir from a local HTTP server; use its URL as the API base URL
+func NewServer(dir string) (*httptest.Server, error) {
+	fixtures, err := Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(fixtures) == 0 {
+		return nil, fmt.Errorf("no fixtures in %s", dir)
+	}
+	log.Printf("fixture: replay %d responses from %s", len(fixtures), dir)
+	return httptest.NewServer(Handler(fixtures)), nil
+}
@@ -0,0 +1,138 @@
+package fixture
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// upstream stands in for the GitHub API with a JSON page, a GraphQL endpoint, a text response and an error
+func upstream(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		switch r.Method + " " + r.URL.Path {
+		case "GET /repos/kokkos/kokkos/issues":
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Link", `<https://api.github.com/repositories/1/issues?page=2>; rel="next"`)
+			io.WriteString(w, `[{"number": 101, "title": "Add SIMD reductions"}]`)
+		case "POST /graphql":
+			var req struct {
+				Variables struct {
+					Owner string `json:"owner"`
+				} `json:"variables"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Errorf("GraphQL request: %v", err)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"data": {"repository": {"name": %q}}}`, req.Variables.Owner)
+		case "GET /zen":
+			w.Header().Set("Content-Type", "text/plain")
+			io.WriteString(w, "Keep it logically awesome.")
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"message": "Not Found"}`)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// response is what a test compares of a response
+type response struct {
+	status                  int
+	body                    string
+	contentType, link, rate string
+	cookie                  string // which fixtures must not keep
+}
+
+func do(t *testing.T, client *http.Client, method, url, body string) response {
+	t.Helper()
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// fixtures indent JSON bodies
+	var compact bytes.Buffer
+	if json.Compact(&compact, data) == nil {
+		data = compact.Bytes()
+	}
+	return response{resp.StatusCode, string(data), resp.Header.Get("Content-Type"), resp.Header.Get("Link"),
+		resp.Header.Get("X-RateLimit-Remaining"), resp.Header.Get("Set-Cookie")}
+}
+
+// request is one request of the round trip
+type request struct {
+	method, path, body string
+}
+
+func TestRecordReplay(t *testing.T) {
+	upstream := upstream(t)
+	dir := t.TempDir()
+	recording := &http.Client{Transport: NewRecorder(dir, nil)}
+
+	requests := []request{
+		{"GET", "/repos/kokkos/kokkos/issues?state=all&since=2025-06-02T12:00:00Z&per_page=100", ""},
+		{"POST", "/graphql", `{"query": "query($owner: String!) { repository(owner: $owner) { name } }", "variables": {"owner": "kokkos", "since": "2025-06-02T12:00:00Z"}}`},
+		{"POST", "/graphql", `{"query": "query($owner: String!) { repository(owner: $owner) { name } }", "variables": {"owner": "kokkos-kernels"}}`},
+		{"GET", "/zen", ""},
+		{"GET", "/repos/kokkos/missing", ""},
+	}
+	recorded := map[request]response{}
+	for _, req := range requests {
+		resp := do(t, recording, req.method, upstream.URL+req.path, req.body)
+		if resp.cookie == "" {
+			t.Errorf("%s %s: the recorder didn't pass Set-Cookie on", req.method, req.path)
+		}
+		resp.cookie = ""
+		recorded[req] = resp
+	}
+
+	names, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != len(requests) {
+		t.Errorf("%d fixtures for %d requests", len(names), len(requests))
+	}
+
+	server, err := NewServer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	for _, req := range requests {
+		// since changes from run to run, so another value must get the same response
+		replayed := req
+		replayed.path = strings.Replace(req.path, "since=2025-06-02T12:00:00Z", "since=2025-06-05T12:00:00Z", 1)
+		replayed.body = strings.Replace(req.body, `"since": "2025-06-02T12:00:00Z"`, `"since": "2025-06-05T12:00:00Z"`, 1)
+		if got, want := do(t, http.DefaultClient, replayed.method, server.URL+replayed.path, replayed.body), recorded[req]; got != want {
+			t.Errorf("%s %s replayed %+v, recorded %+v", req.method, req.path, got, want)
+		}
+	}
+
+	if got := do(t, http.DefaultClient, "GET", server.URL+"/repos/kokkos/kokkos/pulls", ""); got.status != http.StatusNotFound {
+		t.Errorf("unrecorded request: status %d, want %d", got.status, http.StatusNotFound)
+	}
+}
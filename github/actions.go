@@ -0,0 +1,83 @@
+package github
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// WorkflowRun is one run of a GitHub Actions workflow
+type WorkflowRun struct {
+	ID           int64     `json:"id"`
+	Name         string    `json:"name"`
+	WorkflowID   int64     `json:"workflow_id"`
+	HeadBranch   string    `json:"head_branch"`
+	HeadSHA      string    `json:"head_sha"`
+	Event        string    `json:"event"`
+	Status       string    `json:"status"`     // "queued", "in_progress", "completed", ...
+	Conclusion   string    `json:"conclusion"` // "success", "failure", "cancelled", ..., or empty until completed
+	RunNumber    int       `json:"run_number"`
+	RunAttempt   int       `json:"run_attempt"`
+	HTMLURL      string    `json:"html_url"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	RunStartedAt time.Time `json:"run_started_at"`
+}
+
+// Completed is true once the run has a conclusion
+func (r WorkflowRun) Completed() bool {
+	return r.Status == "completed"
+}
+
+// Duration is how long the run took, or has taken so far
+func (r WorkflowRun) Duration() time.Duration {
+	if r.RunStartedAt.IsZero() {
+		return 0
+	}
+	return r.UpdatedAt.Sub(r.RunStartedAt)
+}
+
+// GetDefaultBranch retrieves the name of a repository's default branch
+func (c *Client) GetDefaultBranch(owner, repo string) (string, error) {
+	var repository struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if err := c.get(fmt.Sprintf("/repos/%s/%s", owner, repo), nil, &repository); err != nil {
+		return "", err
+	}
+	return repository.DefaultBranch, nil
+}
+
+// GetWorkflowRuns retrieves the workflow runs on a branch triggered by an event, newest first,
+// until one created before since. The last page also has the runs just before since, which
+// tell whether a workflow was already failing.
+// An empty branch or event matches any.
+func (c *Client) GetWorkflowRuns(owner, repo, branch, event string, since time.Time) ([]WorkflowRun, error) {
+	var runs []WorkflowRun
+	perPage := 100
+
+	for page := 1; ; page++ {
+		query := url.Values{
+			"page":     {strconv.Itoa(page)},
+			"per_page": {strconv.Itoa(perPage)},
+		}
+		if branch != "" {
+			query.Set("branch", branch)
+		}
+		if event != "" {
+			query.Set("event", event)
+		}
+		var result struct {
+			WorkflowRuns []WorkflowRun `json:"workflow_runs"`
+		}
+		if err := c.get(fmt.Sprintf("/repos/%s/%s/actions/runs", owner, repo), query, &result); err != nil {
+			return nil, err
+		}
+		items := result.WorkflowRuns
+		runs = append(runs, items...)
+		if len(items) < perPage || items[len(items)-1].CreatedAt.Before(since) {
+			return runs, nil
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package github
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Auth supplies the credentials of API requests
+type Auth interface {
+	// Authorization is the Authorization header of the next request, or "" for none
+	Authorization() (string, error)
+	// RequestsPerHour is the primary rate limit of the credentials
+	RequestsPerHour() int
+}
+
+// TokenAuth authenticates with a personal access token, or not at all if token is empty
+func TokenAuth(token string) Auth {
+	return tokenAuth(token)
+}
+
+type tokenAuth string
+
+func (t tokenAuth) Authorization() (string, error) {
+	if t == "" {
+		return "", nil
+	}
+	return "Bearer " + string(t), nil
+}
+
+func (t tokenAuth) RequestsPerHour() int {
+	if t == "" {
+		return 60 // per IP address
+	}
+	return 5000
+}
+
+// appTokenMargin is how long before it expires an installation token is replaced
+const appTokenMargin = 5 * time.Minute
+
+// AppAuth authenticates as an installation of a GitHub App.
+// It exchanges a JWT signed with the app's private key for an installation token,
+// which it reuses until shortly before it expires.
+type AppAuth struct {
+	appID          string
+	installationID int64
+	key            *rsa.PrivateKey
+	opts           Options
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// NewAppAuth authenticates as installationID of the app with ID or client ID appID, using its PEM-encoded private key.
+// Installation tokens come from the API configured by opts.
+func NewAppAuth(appID string, installationID int64, privateKey []byte, opts Options) (*AppAuth, error) {
+	key, err := parsePrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("GitHub App private key: %w", err)
+	}
+	return &AppAuth{
+		appID:          appID,
+		installationID: installationID,
+		key:            key,
+		opts:           opts.withDefaults(),
+	}, nil
+}
+
+// parsePrivateKey reads the PKCS #1 key GitHub generates, or a PKCS #8 one
+func parsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+func (a *AppAuth) Authorization() (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token == "" || !time.Now().Before(a.expires.Add(-appTokenMargin)) {
+		if err := a.refresh(); err != nil {
+			return "", err
+		}
+	}
+	return "Bearer " + a.token, nil
+}
+
+func (a *AppAuth) RequestsPerHour() int {
+	return 5000 // at least; more for large organizations
+}
+
+// refresh replaces the installation token
+func (a *AppAuth) refresh() error {
+	jwt, err := a.jwt()
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/app/installations/%d/access_tokens", a.opts.BaseURL, a.installationID)
+	req, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", a.opts.APIVersion)
+	req.Header.Set("User-Agent", a.opts.UserAgent)
+
+	resp, err := a.opts.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("GitHub App installation token: %s", resp.Status)
+	}
+
+	var result struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("GitHub App installation token: %w", err)
+	}
+	if result.Token == "" {
+		return fmt.Errorf("GitHub App installation token: empty response")
+	}
+	a.token = result.Token
+	a.expires = result.ExpiresAt
+	return nil
+}
+
+// jwt is a token that authenticates as the app itself, signed with RS256
+func (a *AppAuth) jwt() (string, error) {
+	now := time.Now()
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iat": now.Add(-time.Minute).Unix(), // allow for clock drift
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": a.appID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var b bytes.Buffer
+	b.WriteString(base64.RawURLEncoding.EncodeToString(header))
+	b.WriteByte('.')
+	b.WriteString(base64.RawURLEncoding.EncodeToString(claims))
+
+	digest := sha256.Sum256(b.Bytes())
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	b.WriteByte('.')
+	b.WriteString(base64.RawURLEncoding.EncodeToString(signature))
+	return b.String(), nil
+}
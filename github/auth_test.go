@@ -0,0 +1,214 @@
+package github
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testAppKey is generated once; RSA key generation is slow
+var testAppKey = sync.OnceValue(func() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	return key
+})
+
+// tokenServer stands in for the installation token endpoint of installation 42.
+// It checks the JWT of each request and issues tokens that expire after lifetime.
+type tokenServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	issued   int
+	lifetime time.Duration
+}
+
+func newTokenServer(t *testing.T, appID string, lifetime time.Duration) *tokenServer {
+	s := &tokenServer{lifetime: lifetime}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/app/installations/42/access_tokens" {
+			t.Errorf("%s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		jwt, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			t.Errorf("Authorization %q", r.Header.Get("Authorization"))
+		}
+		if err := checkAppJWT(jwt, appID, &testAppKey().PublicKey); err != nil {
+			t.Error(err)
+			http.Error(w, "bad JWT", http.StatusUnauthorized)
+			return
+		}
+
+		s.mu.Lock()
+		s.issued++
+		token := fmt.Sprintf("ghs_%d", s.issued)
+		s.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]any{"token": token, "expires_at": time.Now().Add(s.lifetime).UTC()})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *tokenServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.issued
+}
+
+// checkAppJWT verifies what GitHub requires of the JWT of a GitHub App
+func checkAppJWT(jwt, appID string, key *rsa.PublicKey) error {
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("JWT has %d parts", len(parts))
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Typ string `json:"typ"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return err
+	}
+	if header.Alg != "RS256" || header.Typ != "JWT" {
+		return fmt.Errorf("JWT header %+v", header)
+	}
+
+	var claims struct {
+		IAT int64  `json:"iat"`
+		EXP int64  `json:"exp"`
+		ISS string `json:"iss"`
+	}
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return err
+	}
+	now := time.Now().Unix()
+	if claims.ISS != appID {
+		return fmt.Errorf("JWT issued by %q, want %q", claims.ISS, appID)
+	}
+	if claims.IAT > now || claims.EXP <= now || claims.EXP-claims.IAT > 10*60 {
+		return fmt.Errorf("JWT valid from %d to %d at %d; GitHub allows at most 10 minutes", claims.IAT, claims.EXP, now)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return fmt.Errorf("JWT signature: %w", err)
+	}
+	return nil
+}
+
+func decodeJWTPart(part string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// pkcs1PEM encodes key the way GitHub does for App private keys
+func pkcs1PEM(key *rsa.PrivateKey) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+}
+
+func authorize(t *testing.T, auth Auth) string {
+	t.Helper()
+	authorization, err := auth.Authorization()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return authorization
+}
+
+func TestAppAuthCachesToken(t *testing.T) {
+	server := newTokenServer(t, "Iv1.abc123", time.Hour)
+	auth, err := NewAppAuth("Iv1.abc123", 42, pkcs1PEM(testAppKey()), Options{BaseURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range 3 {
+		if got := authorize(t, auth); got != "Bearer ghs_1" {
+			t.Errorf("Authorization %q, want Bearer ghs_1", got)
+		}
+	}
+	if n := server.count(); n != 1 {
+		t.Errorf("%d tokens requested, want 1", n)
+	}
+}
+
+func TestAppAuthRefreshesBeforeExpiry(t *testing.T) {
+	// every token expires within appTokenMargin, so none is reused
+	server := newTokenServer(t, "12345", appTokenMargin-time.Minute)
+	auth, err := NewAppAuth("12345", 42, pkcs1PEM(testAppKey()), Options{BaseURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= 3; i++ {
+		if got, want := authorize(t, auth), fmt.Sprintf("Bearer ghs_%d", i); got != want {
+			t.Errorf("Authorization %q, want %q", got, want)
+		}
+	}
+
+	// one that expires just after the margin is still good
+	server.lifetime = appTokenMargin + time.Minute
+	first := authorize(t, auth)
+	if got := authorize(t, auth); got != first {
+		t.Errorf("Authorization %q after %q, want the same token", got, first)
+	}
+}
+
+func TestAppAuthPKCS8Key(t *testing.T) {
+	der, err := x509.MarshalPKCS8PrivateKey(testAppKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newTokenServer(t, "12345", time.Hour)
+	auth, err := NewAppAuth("12345", 42, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), Options{BaseURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := authorize(t, auth); got != "Bearer ghs_1" {
+		t.Errorf("Authorization %q", got)
+	}
+}
+
+func TestAppAuthErrors(t *testing.T) {
+	if _, err := NewAppAuth("12345", 42, []byte("not a key"), Options{}); err == nil {
+		t.Error("no error for a key that isn't PEM")
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message": "A JSON web token could not be decoded"}`, http.StatusUnauthorized)
+	}))
+	defer server.Close()
+	auth, err := NewAppAuth("12345", 42, pkcs1PEM(testAppKey()), Options{BaseURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.Authorization(); err == nil {
+		t.Error("no error when the token endpoint refuses")
+	}
+}
@@ -0,0 +1,68 @@
+package github
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Branch is a branch of a repository and the commit at its head
+type Branch struct {
+	Name   string `json:"name"`
+	Commit struct {
+		SHA string `json:"sha"`
+	} `json:"commit"`
+	Protected bool `json:"protected"`
+}
+
+// BranchCommit is a commit on a branch and the pull requests that brought it there
+type BranchCommit struct {
+	PullRequestCommit
+
+	Branch       string `json:"branch"`
+	PullRequests []int  `json:"pull_requests"` // numbers; empty for a commit pushed straight to the branch
+}
+
+// Direct is true for a commit that no pull request brought to the branch
+func (c BranchCommit) Direct() bool {
+	return len(c.PullRequests) == 0
+}
+
+// GetBranches retrieves every branch of a repository
+func (c *Client) GetBranches(owner, repo string) ([]Branch, error) {
+	return getPages[Branch](c, fmt.Sprintf("/repos/%s/%s/branches", owner, repo))
+}
+
+// GetBranchCommits retrieves the commits on a branch since a given timestamp, newest first
+func (c *Client) GetBranchCommits(owner, repo, branch string, since time.Time) ([]PullRequestCommit, error) {
+	var commits []PullRequestCommit
+	perPage := 100
+
+	for page := 1; ; page++ {
+		query := url.Values{
+			"sha":      {branch},
+			"since":    {since.Format(time.RFC3339)},
+			"page":     {strconv.Itoa(page)},
+			"per_page": {strconv.Itoa(perPage)},
+		}
+		var items []PullRequestCommit
+		if err := c.get(fmt.Sprintf("/repos/%s/%s/commits", owner, repo), query, &items); err != nil {
+			return nil, err
+		}
+		commits = append(commits, items...)
+		if len(items) < perPage {
+			return commits, nil
+		}
+	}
+}
+
+// GetCommitPullRequests retrieves the pull requests associated with a commit:
+// the merged ones that brought it to the default branch, or the open ones that contain it
+func (c *Client) GetCommitPullRequests(owner, repo, sha string) ([]PullRequest, error) {
+	var prs []PullRequest
+	if err := c.get(fmt.Sprintf("/repos/%s/%s/commits/%s/pulls", owner, repo, sha), nil, &prs); err != nil {
+		return nil, err
+	}
+	return prs, nil
+}
@@ -4,28 +4,34 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
 	"kokkos-dashboard/ratelimit"
 )
 
+// DefaultBaseURL is the public GitHub REST API
+const DefaultBaseURL = "https://api.github.com"
+
 type Client struct {
-	token    string
+	opts     Options
 	rlClient *ratelimit.Client
-	baseURL  string
 }
 
 type Issue struct {
-	Number    int       `json:"number"`
-	Title     string    `json:"title"`
-	State     string    `json:"state"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	HTMLURL   string    `json:"html_url"`
+	Number    int        `json:"number"`
+	Title     string     `json:"title"`
+	State     string     `json:"state"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	ClosedAt  *time.Time `json:"closed_at"`
+	HTMLURL   string     `json:"html_url"`
 	User      struct {
 		Login string `json:"login"`
 	} `json:"user"`
 	PullRequest *struct{} `json:"pull_request,omitempty"` // to filter out PRs
+	Reactions   Reactions `json:"reactions"`
 }
 
 type PullRequest struct {
@@ -53,7 +59,8 @@ type IssueComment struct {
 		Login     string `json:"login"`
 		AvatarURL string `json:"avatar_url"`
 	} `json:"user"`
-	IssueURL string `json:"issue_url"`
+	IssueURL  string    `json:"issue_url"`
+	Reactions Reactions `json:"reactions"`
 }
 
 // User represents a GitHub user
@@ -157,244 +164,155 @@ type PullRequestReview struct {
 }
 
 func NewClient(token string) *Client {
+	return NewClientWithOptions(Options{Token: token})
+}
 
+// NewClientWithOptions creates a client of the REST API configured by opts
+func NewClientWithOptions(opts Options) *Client {
+	opts = opts.withDefaults()
 	return &Client{
-		token: token,
+		opts: opts,
 		rlClient: ratelimit.NewRateLimitedClientWithHTTPClient(
-			&http.Client{Timeout: 30 * time.Second},
-			time.Hour/5000, // 5k requests per hour
+			opts.HTTPClient,
+			opts.minInterval(),
 		),
-		baseURL: "https://api.github.com",
 	}
 }
 
-func (c *Client) GetRecentIssues(owner, repo string, since time.Time) ([]Issue, error) {
-
-	// TODO: since should be in ISO 8601
-	url := fmt.Sprintf("%s/repos/%s/%s/issues?state=all&sort=updated&since=%s&per_page=100",
-		c.baseURL, owner, repo, since.Format(time.RFC3339))
+// SetMinInterval updates the minimum interval between requests
+func (c *Client) SetMinInterval(interval time.Duration) {
+	c.rlClient.SetMinInterval(interval)
+}
 
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
+// get requests path, relative to the base URL, with query and decodes the JSON response into v
+func (c *Client) get(path string, query url.Values, v any) error {
+	u := c.opts.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
 	}
 
-	req.Header.Set("Authorization", "Bearer "+c.token)
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
-	req.Header.Set("User-Agent", "cwpearson/kokkos-dashboard")
-
-	resp, err := c.rlClient.Do(req)
+	req, err := http.NewRequest("GET", u, nil)
 	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	var issues []Issue
-	if err := json.NewDecoder(resp.Body).Decode(&issues); err != nil {
-		return nil, err
+		return err
 	}
-
-	return issues, nil
-}
-
-// GetIssueComments retrieves all comments for a specific issue since a given timestamp
-func (c *Client) GetIssueComments(owner, repo string, issueNumber int, since time.Time) ([]IssueComment, error) {
-	url := fmt.Sprintf("%s/repos/%s/%s/issues/%d/comments?sort=updated&since=%s&per_page=100",
-		c.baseURL, owner, repo, issueNumber, since.Format(time.RFC3339))
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
+	if err := c.opts.setHeaders(req); err != nil {
+		return err
 	}
 
-	req.Header.Set("Authorization", "Bearer "+c.token)
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
-	req.Header.Set("User-Agent", "cwpearson/kokkos-dashboard")
-
 	resp, err := c.rlClient.Do(req)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer resp.Body.Close()
+	c.opts.observe(req, resp)
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("GitHub API error: %s", resp.Status)
-	}
-
-	var comments []IssueComment
-	if err := json.NewDecoder(resp.Body).Decode(&comments); err != nil {
-		return nil, err
+		return fmt.Errorf("GitHub API error: %s", resp.Status)
 	}
-
-	return comments, nil
+	return json.NewDecoder(resp.Body).Decode(v)
 }
 
-// GetIssueEvents retrieves all events for a specific issue
-func (c *Client) GetIssueEvents(owner, repo string, issueNumber int) ([]IssueEvent, error) {
-	var allEvents []IssueEvent
-	page := 1
+// getPages requests every page of the list at path
+func getPages[T any](c *Client, path string) ([]T, error) {
+	var all []T
 	perPage := 100
 
-	for {
-		url := fmt.Sprintf("%s/repos/%s/%s/issues/%d/events?page=%d&per_page=%d",
-			c.baseURL, owner, repo, issueNumber, page, perPage)
-
-		req, err := http.NewRequest("GET", url, nil)
-		if err != nil {
-			return nil, err
-		}
-
-		req.Header.Set("Authorization", "Bearer "+c.token)
-		req.Header.Set("Accept", "application/vnd.github.v3+json")
-		req.Header.Set("User-Agent", "cwpearson/kokkos-dashboard")
-
-		resp, err := c.rlClient.Do(req)
-		if err != nil {
-			return nil, err
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("GitHub API error: %s", resp.Status)
+	for page := 1; ; page++ {
+		query := url.Values{
+			"page":     {strconv.Itoa(page)},
+			"per_page": {strconv.Itoa(perPage)},
 		}
-
-		var events []IssueEvent
-		if err := json.NewDecoder(resp.Body).Decode(&events); err != nil {
+		var items []T
+		if err := c.get(path, query, &items); err != nil {
 			return nil, err
 		}
-
-		allEvents = append(allEvents, events...)
+		all = append(all, items...)
 
 		// Check if there are more pages
-		if len(events) < perPage {
-			break
+		if len(items) < perPage {
+			return all, nil
 		}
-		page++
 	}
-
-	return allEvents, nil
 }
 
-// GetPullRequestCommits retrieves all commits for a specific pull request
-func (c *Client) GetPullRequestCommits(owner, repo string, pullNumber int) ([]PullRequestCommit, error) {
-	var allCommits []PullRequestCommit
-	page := 1
-	perPage := 100
-
-	for {
-		url := fmt.Sprintf("%s/repos/%s/%s/pulls/%d/commits?page=%d&per_page=%d",
-			c.baseURL, owner, repo, pullNumber, page, perPage)
-
-		req, err := http.NewRequest("GET", url, nil)
-		if err != nil {
-			return nil, err
-		}
-
-		req.Header.Set("Authorization", "Bearer "+c.token)
-		req.Header.Set("Accept", "application/vnd.github.v3+json")
-		req.Header.Set("User-Agent", "cwpearson/kokkos-dashboard")
-
-		resp, err := c.rlClient.Do(req)
-		if err != nil {
-			return nil, err
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("GitHub API error: %s", resp.Status)
-		}
-
-		var commits []PullRequestCommit
-		if err := json.NewDecoder(resp.Body).Decode(&commits); err != nil {
-			return nil, err
-		}
-
-		allCommits = append(allCommits, commits...)
-
-		// Check if there are more pages
-		if len(commits) < perPage {
-			break
-		}
-		page++
+func (c *Client) GetRecentIssues(owner, repo string, since time.Time) ([]Issue, error) {
+	query := url.Values{
+		"state":    {"all"},
+		"sort":     {"updated"},
+		"since":    {since.Format(time.RFC3339)},
+		"per_page": {"100"},
 	}
-
-	return allCommits, nil
+	var issues []Issue
+	if err := c.get(fmt.Sprintf("/repos/%s/%s/issues", owner, repo), query, &issues); err != nil {
+		return nil, err
+	}
+	return issues, nil
 }
 
-// GetPullRequest retrieves a specific pull request by its number
-func (c *Client) GetPullRequest(owner, repo string, pullNumber int) (*PullRequest, error) {
-	url := fmt.Sprintf("%s/repos/%s/%s/pulls/%d",
-		c.baseURL, owner, repo, pullNumber)
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
+// GetIssue retrieves a specific issue or pull request by its number
+func (c *Client) GetIssue(owner, repo string, issueNumber int) (*Issue, error) {
+	var issue Issue
+	if err := c.get(fmt.Sprintf("/repos/%s/%s/issues/%d", owner, repo, issueNumber), nil, &issue); err != nil {
 		return nil, err
 	}
+	return &issue, nil
+}
 
-	req.Header.Set("Authorization", "Bearer "+c.token)
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
-	req.Header.Set("User-Agent", "cwpearson/kokkos-dashboard")
-
-	resp, err := c.rlClient.Do(req)
-	if err != nil {
+// GetIssueComments retrieves all comments for a specific issue since a given timestamp
+func (c *Client) GetIssueComments(owner, repo string, issueNumber int, since time.Time) ([]IssueComment, error) {
+	query := url.Values{
+		"sort":     {"updated"},
+		"since":    {since.Format(time.RFC3339)},
+		"per_page": {"100"},
+	}
+	var comments []IssueComment
+	if err := c.get(fmt.Sprintf("/repos/%s/%s/issues/%d/comments", owner, repo, issueNumber), query, &comments); err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	return comments, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("GitHub API error: %s", resp.Status)
-	}
+// GetIssueEvents retrieves all events for a specific issue
+func (c *Client) GetIssueEvents(owner, repo string, issueNumber int) ([]IssueEvent, error) {
+	return getPages[IssueEvent](c, fmt.Sprintf("/repos/%s/%s/issues/%d/events", owner, repo, issueNumber))
+}
+
+// GetPullRequestCommits retrieves all commits for a specific pull request
+func (c *Client) GetPullRequestCommits(owner, repo string, pullNumber int) ([]PullRequestCommit, error) {
+	return getPages[PullRequestCommit](c, fmt.Sprintf("/repos/%s/%s/pulls/%d/commits", owner, repo, pullNumber))
+}
 
+// GetPullRequest retrieves a specific pull request by its number
+func (c *Client) GetPullRequest(owner, repo string, pullNumber int) (*PullRequest, error) {
 	var pr PullRequest
-	if err := json.NewDecoder(resp.Body).Decode(&pr); err != nil {
+	if err := c.get(fmt.Sprintf("/repos/%s/%s/pulls/%d", owner, repo, pullNumber), nil, &pr); err != nil {
 		return nil, err
 	}
-
 	return &pr, nil
 }
 
 // GetPullRequestReviews retrieves all reviews for a specific pull request
 func (c *Client) GetPullRequestReviews(owner, repo string, pullNumber int) ([]PullRequestReview, error) {
-	var allReviews []PullRequestReview
-	page := 1
-	perPage := 100
-
-	for {
-		url := fmt.Sprintf("%s/repos/%s/%s/pulls/%d/reviews?page=%d&per_page=%d",
-			c.baseURL, owner, repo, pullNumber, page, perPage)
-
-		req, err := http.NewRequest("GET", url, nil)
-		if err != nil {
-			return nil, err
-		}
-
-		req.Header.Set("Authorization", "Bearer "+c.token)
-		req.Header.Set("Accept", "application/vnd.github.v3+json")
-		req.Header.Set("User-Agent", "cwpearson/kokkos-dashboard")
-
-		resp, err := c.rlClient.Do(req)
-		if err != nil {
-			return nil, err
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("GitHub API error: %s", resp.Status)
-		}
+	return getPages[PullRequestReview](c, fmt.Sprintf("/repos/%s/%s/pulls/%d/reviews", owner, repo, pullNumber))
+}
 
-		var reviews []PullRequestReview
-		if err := json.NewDecoder(resp.Body).Decode(&reviews); err != nil {
-			return nil, err
-		}
+// OpenIssuesQuery searches the open issues of a repository
+func OpenIssuesQuery(owner, repo string) string {
+	return fmt.Sprintf("repo:%s/%s is:issue is:open", owner, repo)
+}
 
-		allReviews = append(allReviews, reviews...)
+// OpenPullRequestsQuery searches the open pull requests of a repository
+func OpenPullRequestsQuery(owner, repo string) string {
+	return fmt.Sprintf("repo:%s/%s is:pr is:open", owner, repo)
+}
 
-		// Check if there are more pages
-		if len(reviews) < perPage {
-			break
-		}
-		page++
+// CountIssues returns how many issues and pull requests match a search query
+func (c *Client) CountIssues(query string) (int, error) {
+	var result struct {
+		TotalCount int `json:"total_count"`
 	}
-
-	return allReviews, nil
+	if err := c.get("/search/issues", url.Values{"q": {query}, "per_page": {"1"}}, &result); err != nil {
+		return 0, err
+	}
+	return result.TotalCount, nil
 }
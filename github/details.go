@@ -0,0 +1,58 @@
+package github
+
+import "time"
+
+// IssueDetails is an issue or pull request with everything the dashboard shows about it
+type IssueDetails struct {
+	Issue    Issue
+	Comments []IssueComment // updated since the time asked for
+	Events   []IssueEvent
+	Commits  []PullRequestCommit // pull requests only
+	PR       *PullRequest        // pull requests only
+	Reviews  []PullRequestReview // pull requests only
+}
+
+// GetIssueDetails retrieves the comments and events of issue and, for a pull request, its commits and reviews
+func (c *Client) GetIssueDetails(owner, repo string, issue Issue, since time.Time) (*IssueDetails, error) {
+	details := &IssueDetails{Issue: issue}
+
+	var err error
+	if details.Comments, err = c.GetIssueComments(owner, repo, issue.Number, since); err != nil {
+		return nil, err
+	}
+	if details.Events, err = c.GetIssueEvents(owner, repo, issue.Number); err != nil {
+		return nil, err
+	}
+	if issue.PullRequest == nil {
+		return details, nil
+	}
+
+	if details.Commits, err = c.GetPullRequestCommits(owner, repo, issue.Number); err != nil {
+		return nil, err
+	}
+	if details.PR, err = c.GetPullRequest(owner, repo, issue.Number); err != nil {
+		return nil, err
+	}
+	if details.Reviews, err = c.GetPullRequestReviews(owner, repo, issue.Number); err != nil {
+		return nil, err
+	}
+	return details, nil
+}
+
+// GetRecentIssueDetails retrieves the issues and pull requests updated since a time, with their details
+func (c *Client) GetRecentIssueDetails(owner, repo string, since time.Time) ([]IssueDetails, error) {
+	issues, err := c.GetRecentIssues(owner, repo, since)
+	if err != nil {
+		return nil, err
+	}
+
+	var all []IssueDetails
+	for _, issue := range issues {
+		details, err := c.GetIssueDetails(owner, repo, issue, since)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, *details)
+	}
+	return all, nil
+}
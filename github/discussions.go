@@ -0,0 +1,158 @@
+package github
+
+import "time"
+
+// Discussion is a thread in a repository's Discussions, which only the GraphQL API serves
+type Discussion struct {
+	Number         int                 `json:"number"`
+	Title          string              `json:"title"`
+	Body           string              `json:"body"`
+	HTMLURL        string              `json:"html_url"`
+	CreatedAt      time.Time           `json:"created_at"`
+	UpdatedAt      time.Time           `json:"updated_at"`
+	Closed         bool                `json:"closed"`
+	Answered       bool                `json:"answered"`
+	AnswerChosenAt *time.Time          `json:"answer_chosen_at"`
+	User           *User               `json:"user"`
+	Category       DiscussionCategory  `json:"category"`
+	Comments       []DiscussionComment `json:"comments"` // updated since the time asked for
+}
+
+// DiscussionCategory groups discussions; only answerable ones, like Q&A, can be answered
+type DiscussionCategory struct {
+	Name         string `json:"name"`
+	IsAnswerable bool   `json:"is_answerable"`
+}
+
+// DiscussionComment is a top-level comment on a discussion
+type DiscussionComment struct {
+	ID        int64     `json:"id"`
+	Body      string    `json:"body"`
+	HTMLURL   string    `json:"html_url"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	IsAnswer  bool      `json:"is_answer"`
+	User      *User     `json:"user"`
+}
+
+const gqlDiscussionCommentFields = `nodes { databaseId body url createdAt updatedAt isAnswer author { ` + gqlActorFields + ` } } ` + gqlPageInfoFields
+
+type gqlDiscussion struct {
+	ID             string     `json:"id"`
+	Number         int        `json:"number"`
+	Title          string     `json:"title"`
+	Body           string     `json:"body"`
+	URL            string     `json:"url"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+	Closed         bool       `json:"closed"`
+	IsAnswered     bool       `json:"isAnswered"`
+	AnswerChosenAt *time.Time `json:"answerChosenAt"`
+	Author         *gqlActor  `json:"author"`
+	Category       struct {
+		Name         string `json:"name"`
+		IsAnswerable bool   `json:"isAnswerable"`
+	} `json:"category"`
+	Comments gqlConnection[struct {
+		DatabaseID int64     `json:"databaseId"`
+		Body       string    `json:"body"`
+		URL        string    `json:"url"`
+		CreatedAt  time.Time `json:"createdAt"`
+		UpdatedAt  time.Time `json:"updatedAt"`
+		IsAnswer   bool      `json:"isAnswer"`
+		Author     *gqlActor `json:"author"`
+	}] `json:"comments"`
+}
+
+// GetRecentDiscussions retrieves the discussions updated since a time, most recently updated first,
+// with their comments updated since then. A repository without Discussions has none.
+func (c *GraphQLClient) GetRecentDiscussions(owner, repo string, since time.Time) ([]Discussion, error) {
+	const query = `query($owner: String!, $name: String!, $cursor: String) {
+  repository(owner: $owner, name: $name) {
+    hasDiscussionsEnabled
+    discussions(first: 25, after: $cursor, orderBy: {field: UPDATED_AT, direction: DESC}) {
+      nodes {
+        id number title body url createdAt updatedAt closed isAnswered answerChosenAt
+        author { ` + gqlActorFields + ` }
+        category { name isAnswerable }
+        comments(first: 100) { ` + gqlDiscussionCommentFields + ` }
+      }
+      ` + gqlPageInfoFields + `
+    }
+  }
+}`
+
+	discussions := []Discussion{}
+	variables := map[string]any{"owner": owner, "name": repo, "cursor": nil}
+	for {
+		var data struct {
+			Repository struct {
+				HasDiscussionsEnabled bool                          `json:"hasDiscussionsEnabled"`
+				Discussions           gqlConnection[*gqlDiscussion] `json:"discussions"`
+			} `json:"repository"`
+		}
+		if err := c.query(query, variables, &data); err != nil {
+			return nil, err
+		}
+		if !data.Repository.HasDiscussionsEnabled {
+			return discussions, nil
+		}
+
+		conn := data.Repository.Discussions
+		for _, node := range conn.Nodes {
+			if node.UpdatedAt.Before(since) {
+				return discussions, nil
+			}
+			discussion, err := c.discussion(node, since)
+			if err != nil {
+				return nil, err
+			}
+			discussions = append(discussions, discussion)
+		}
+		if !conn.PageInfo.HasNextPage || len(conn.Nodes) == 0 {
+			return discussions, nil
+		}
+		variables["cursor"] = conn.PageInfo.EndCursor
+	}
+}
+
+// discussion retrieves the rest of the comments of d and converts it
+func (c *GraphQLClient) discussion(d *gqlDiscussion, since time.Time) (Discussion, error) {
+	comments, err := morePages(c, d.ID, "Discussion", "comments", "", gqlDiscussionCommentFields, d.Comments)
+	if err != nil {
+		return Discussion{}, err
+	}
+
+	discussion := Discussion{
+		Number:         d.Number,
+		Title:          d.Title,
+		Body:           d.Body,
+		HTMLURL:        d.URL,
+		CreatedAt:      d.CreatedAt,
+		UpdatedAt:      d.UpdatedAt,
+		Closed:         d.Closed,
+		Answered:       d.IsAnswered,
+		AnswerChosenAt: d.AnswerChosenAt,
+		User:           d.Author.user(),
+		Category: DiscussionCategory{
+			Name:         d.Category.Name,
+			IsAnswerable: d.Category.IsAnswerable,
+		},
+		Comments: []DiscussionComment{},
+	}
+	for _, comment := range comments {
+		if comment.UpdatedAt.Before(since) {
+			continue
+		}
+		discussion.Comments = append(discussion.Comments, DiscussionComment{
+			ID:        comment.DatabaseID,
+			Body:      comment.Body,
+			HTMLURL:   comment.URL,
+			CreatedAt: comment.CreatedAt,
+			UpdatedAt: comment.UpdatedAt,
+			IsAnswer:  comment.IsAnswer,
+			User:      comment.Author.user(),
+		})
+	}
+	return discussion, nil
+}
@@ -0,0 +1,583 @@
+package github
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"kokkos-dashboard/ratelimit"
+)
+
+// GraphQLClient retrieves the same data as Client through the GraphQL API.
+// An issue and everything attached to it usually come in the same request as dozens of other issues.
+type GraphQLClient struct {
+	opts     Options
+	endpoint string
+	rlClient *ratelimit.Client
+}
+
+// NewGraphQLClient creates a client of the GraphQL API next to the REST API in opts.BaseURL
+func NewGraphQLClient(opts Options) *GraphQLClient {
+	opts = opts.withDefaults()
+	return &GraphQLClient{
+		opts:     opts,
+		endpoint: opts.GraphQLEndpoint(),
+		rlClient: ratelimit.NewRateLimitedClientWithHTTPClient(
+			opts.HTTPClient,
+			opts.minInterval(),
+		),
+	}
+}
+
+// SetMinInterval updates the minimum interval between requests
+func (c *GraphQLClient) SetMinInterval(interval time.Duration) {
+	c.rlClient.SetMinInterval(interval)
+}
+
+// query runs a GraphQL query and decodes its data into v
+func (c *GraphQLClient) query(query string, variables map[string]any, v any) error {
+	body, err := json.Marshal(map[string]any{"query": query, "variables": variables})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	if err := c.opts.setHeaders(req); err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.rlClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	c.opts.observe(req, resp)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GitHub GraphQL API error: %s", resp.Status)
+	}
+
+	var result struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+	if len(result.Errors) > 0 {
+		return fmt.Errorf("GitHub GraphQL API error: %s", result.Errors[0].Message)
+	}
+	return json.Unmarshal(result.Data, v)
+}
+
+type gqlPageInfo struct {
+	HasNextPage bool   `json:"hasNextPage"`
+	EndCursor   string `json:"endCursor"`
+}
+
+type gqlConnection[T any] struct {
+	Nodes    []T         `json:"nodes"`
+	PageInfo gqlPageInfo `json:"pageInfo"`
+}
+
+type gqlActor struct {
+	Login     string `json:"login"`
+	AvatarURL string `json:"avatarUrl"`
+	URL       string `json:"url"`
+}
+
+type gqlComment struct {
+	DatabaseID     int                `json:"databaseId"`
+	Body           string             `json:"body"`
+	CreatedAt      time.Time          `json:"createdAt"`
+	UpdatedAt      time.Time          `json:"updatedAt"`
+	URL            string             `json:"url"`
+	Author         *gqlActor          `json:"author"`
+	ReactionGroups []gqlReactionGroup `json:"reactionGroups"`
+}
+
+type gqlTimelineItem struct {
+	Typename  string    `json:"__typename"`
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	Actor     *gqlActor `json:"actor"`
+	Commit    *struct {
+		OID string `json:"oid"`
+		URL string `json:"url"`
+	} `json:"commit"`
+}
+
+type gqlGitActor struct {
+	Name  string    `json:"name"`
+	Email string    `json:"email"`
+	Date  time.Time `json:"date"`
+	User  *gqlActor `json:"user"`
+}
+
+type gqlCommit struct {
+	Commit struct {
+		OID       string      `json:"oid"`
+		Message   string      `json:"message"`
+		URL       string      `json:"url"`
+		Author    gqlGitActor `json:"author"`
+		Committer gqlGitActor `json:"committer"`
+		Signature *struct {
+			IsValid bool   `json:"isValid"`
+			State   string `json:"state"`
+		} `json:"signature"`
+	} `json:"commit"`
+}
+
+type gqlReview struct {
+	DatabaseID        int64      `json:"databaseId"`
+	Author            *gqlActor  `json:"author"`
+	Body              string     `json:"body"`
+	State             string     `json:"state"`
+	URL               string     `json:"url"`
+	SubmittedAt       *time.Time `json:"submittedAt"`
+	AuthorAssociation string     `json:"authorAssociation"`
+	Commit            *struct {
+		OID string `json:"oid"`
+	} `json:"commit"`
+}
+
+// gqlIssue is an Issue or a PullRequest
+type gqlIssue struct {
+	Typename       string                         `json:"__typename"`
+	ID             string                         `json:"id"`
+	Number         int                            `json:"number"`
+	Title          string                         `json:"title"`
+	State          string                         `json:"state"`
+	CreatedAt      time.Time                      `json:"createdAt"`
+	UpdatedAt      time.Time                      `json:"updatedAt"`
+	ClosedAt       *time.Time                     `json:"closedAt"`
+	URL            string                         `json:"url"`
+	Author         *gqlActor                      `json:"author"`
+	ReactionGroups []gqlReactionGroup             `json:"reactionGroups"`
+	Comments       gqlConnection[gqlComment]      `json:"comments"`
+	TimelineItems  gqlConnection[gqlTimelineItem] `json:"timelineItems"`
+
+	// pull requests only
+	IsDraft  bool                     `json:"isDraft"`
+	Merged   bool                     `json:"merged"`
+	MergedAt *time.Time               `json:"mergedAt"`
+	Commits  gqlConnection[gqlCommit] `json:"commits"`
+	Reviews  gqlConnection[gqlReview] `json:"reviews"`
+}
+
+// timelineEvents are the timeline items fetched as IssueEvents, with their REST event names
+var timelineEvents = []struct {
+	typename string
+	event    string
+	prOnly   bool
+	commit   bool // has a commit field
+}{
+	{"AssignedEvent", "assigned", false, false},
+	{"ClosedEvent", "closed", false, false},
+	{"ConvertToDraftEvent", "convert_to_draft", true, false},
+	{"DemilestonedEvent", "demilestoned", false, false},
+	{"HeadRefDeletedEvent", "head_ref_deleted", true, false},
+	{"HeadRefForcePushedEvent", "head_ref_force_pushed", true, false},
+	{"LabeledEvent", "labeled", false, false},
+	{"LockedEvent", "locked", false, false},
+	{"MergedEvent", "merged", true, true},
+	{"MilestonedEvent", "milestoned", false, false},
+	{"ReadyForReviewEvent", "ready_for_review", true, false},
+	{"ReferencedEvent", "referenced", false, true},
+	{"RenamedTitleEvent", "renamed", false, false},
+	{"ReopenedEvent", "reopened", false, false},
+	{"ReviewDismissedEvent", "review_dismissed", true, false},
+	{"ReviewRequestRemovedEvent", "review_request_removed", true, false},
+	{"ReviewRequestedEvent", "review_requested", true, false},
+	{"UnassignedEvent", "unassigned", false, false},
+	{"UnlabeledEvent", "unlabeled", false, false},
+	{"UnlockedEvent", "unlocked", false, false},
+}
+
+const (
+	gqlPageInfoFields = `pageInfo { hasNextPage endCursor }`
+	gqlActorFields    = `login avatarUrl url`
+	gqlCommentFields  = `nodes { databaseId body createdAt updatedAt url author { ` + gqlActorFields + ` } ` + gqlReactionFields + ` } ` + gqlPageInfoFields
+	gqlCommitFields   = `nodes { commit { oid message url ` +
+		`author { name email date user { ` + gqlActorFields + ` } } ` +
+		`committer { name email date user { ` + gqlActorFields + ` } } ` +
+		`signature { isValid state } } } ` + gqlPageInfoFields
+	// gqlIssueSummaryFields is what an Issue needs of an issue or pull request, without its connections
+	gqlIssueSummaryFields = `__typename id number title state createdAt updatedAt closedAt url author { login } ` + gqlReactionFields
+	gqlReviewFields       = `nodes { databaseId author { ` + gqlActorFields + ` } body state url submittedAt authorAssociation commit { oid } } ` + gqlPageInfoFields
+
+	// nodes per page; a page of issues with their nested connections stays well under GitHub's node limit
+	gqlIssuesPerPage = 25
+	gqlNestedPerPage = 100
+)
+
+// gqlTimeline is the timelineItems arguments and selection for issues or pull requests
+func gqlTimeline(pr bool) (args, fields string) {
+	var types, fragments []string
+	for _, e := range timelineEvents {
+		if e.prOnly && !pr {
+			continue
+		}
+		// e.g. ReadyForReviewEvent -> READY_FOR_REVIEW_EVENT
+		var enum strings.Builder
+		for i, r := range e.typename {
+			if i > 0 && r >= 'A' && r <= 'Z' {
+				enum.WriteByte('_')
+			}
+			enum.WriteRune(r)
+		}
+		types = append(types, strings.ToUpper(enum.String()))
+
+		fragment := `... on ` + e.typename + ` { id createdAt actor { ` + gqlActorFields + ` }`
+		if e.commit {
+			fragment += ` commit { oid url }`
+		}
+		fragments = append(fragments, fragment+` }`)
+	}
+	args = `, itemTypes: [` + strings.Join(types, ", ") + `]`
+	fields = `nodes { __typename ` + strings.Join(fragments, " ") + ` } ` + gqlPageInfoFields
+	return args, fields
+}
+
+// gqlIssueFields selects what IssueDetails needs of an Issue, or with pr of a PullRequest
+func gqlIssueFields(pr bool) string {
+	timelineArgs, timelineFields := gqlTimeline(pr)
+	fields := fmt.Sprintf(gqlIssueSummaryFields+` comments(first: %d) { %s } timelineItems(first: %d%s) { %s }`,
+		gqlNestedPerPage, gqlCommentFields, gqlNestedPerPage, timelineArgs, timelineFields)
+	if pr {
+		fields += fmt.Sprintf(` isDraft merged mergedAt commits(first: %d) { %s } reviews(first: %d) { %s }`,
+			gqlNestedPerPage, gqlCommitFields, gqlNestedPerPage, gqlReviewFields)
+	}
+	return fields
+}
+
+// morePages retrieves the rest of a connection of the node of type kind, like Issue, with the given ID
+func morePages[T any](c *GraphQLClient, id, kind, field, args, fields string, first gqlConnection[T]) ([]T, error) {
+	nodes := first.Nodes
+	page := first.PageInfo
+
+	query := fmt.Sprintf(`query($id: ID!, $after: String) { node(id: $id) { ... on %s { %s(first: %d, after: $after%s) { %s } } } }`,
+		kind, field, gqlNestedPerPage, args, fields)
+	for page.HasNextPage {
+		var data struct {
+			Node map[string]gqlConnection[T] `json:"node"`
+		}
+		if err := c.query(query, map[string]any{"id": id, "after": page.EndCursor}, &data); err != nil {
+			return nil, err
+		}
+		conn := data.Node[field]
+		nodes = append(nodes, conn.Nodes...)
+		page = conn.PageInfo
+	}
+	return nodes, nil
+}
+
+// complete retrieves the remaining pages of every nested connection of issue
+func (c *GraphQLClient) complete(issue *gqlIssue) error {
+	pr := issue.Typename == "PullRequest"
+
+	var err error
+	if issue.Comments.Nodes, err = morePages(c, issue.ID, issue.Typename, "comments", "", gqlCommentFields, issue.Comments); err != nil {
+		return err
+	}
+	timelineArgs, timelineFields := gqlTimeline(pr)
+	if issue.TimelineItems.Nodes, err = morePages(c, issue.ID, issue.Typename, "timelineItems", timelineArgs, timelineFields, issue.TimelineItems); err != nil {
+		return err
+	}
+	if !pr {
+		return nil
+	}
+	if issue.Commits.Nodes, err = morePages(c, issue.ID, issue.Typename, "commits", "", gqlCommitFields, issue.Commits); err != nil {
+		return err
+	}
+	if issue.Reviews.Nodes, err = morePages(c, issue.ID, issue.Typename, "reviews", "", gqlReviewFields, issue.Reviews); err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetRecentIssueDetails retrieves the issues and pull requests updated since a time, with their details
+func (c *GraphQLClient) GetRecentIssueDetails(owner, repo string, since time.Time) ([]IssueDetails, error) {
+	var all []IssueDetails
+
+	// issues can be filtered by update time
+	issuesQuery := fmt.Sprintf(`query($owner: String!, $name: String!, $since: DateTime, $after: String) { `+
+		`repository(owner: $owner, name: $name) { `+
+		`issues(first: %d, after: $after, filterBy: {since: $since}, orderBy: {field: UPDATED_AT, direction: DESC}) { nodes { %s } %s } } }`,
+		gqlIssuesPerPage, gqlIssueFields(false), gqlPageInfoFields)
+	after := ""
+	for {
+		var data struct {
+			Repository struct {
+				Issues gqlConnection[gqlIssue] `json:"issues"`
+			} `json:"repository"`
+		}
+		variables := map[string]any{"owner": owner, "name": repo, "since": since.UTC().Format(time.RFC3339), "after": nullable(after)}
+		if err := c.query(issuesQuery, variables, &data); err != nil {
+			return nil, err
+		}
+		for i := range data.Repository.Issues.Nodes {
+			details, err := c.details(&data.Repository.Issues.Nodes[i], since)
+			if err != nil {
+				return nil, err
+			}
+			all = append(all, *details)
+		}
+		if !data.Repository.Issues.PageInfo.HasNextPage {
+			break
+		}
+		after = data.Repository.Issues.PageInfo.EndCursor
+	}
+
+	// pull requests can't, so page through them newest first until they are older
+	prsQuery := fmt.Sprintf(`query($owner: String!, $name: String!, $after: String) { `+
+		`repository(owner: $owner, name: $name) { `+
+		`pullRequests(first: %d, after: $after, orderBy: {field: UPDATED_AT, direction: DESC}) { nodes { %s } %s } } }`,
+		gqlIssuesPerPage, gqlIssueFields(true), gqlPageInfoFields)
+	after = ""
+	for {
+		var data struct {
+			Repository struct {
+				PullRequests gqlConnection[gqlIssue] `json:"pullRequests"`
+			} `json:"repository"`
+		}
+		variables := map[string]any{"owner": owner, "name": repo, "after": nullable(after)}
+		if err := c.query(prsQuery, variables, &data); err != nil {
+			return nil, err
+		}
+		for i := range data.Repository.PullRequests.Nodes {
+			pr := &data.Repository.PullRequests.Nodes[i]
+			if pr.UpdatedAt.Before(since) {
+				return sortByUpdated(all), nil
+			}
+			details, err := c.details(pr, since)
+			if err != nil {
+				return nil, err
+			}
+			all = append(all, *details)
+		}
+		if !data.Repository.PullRequests.PageInfo.HasNextPage || len(data.Repository.PullRequests.Nodes) == 0 {
+			break
+		}
+		after = data.Repository.PullRequests.PageInfo.EndCursor
+	}
+	return sortByUpdated(all), nil
+}
+
+// GetIssue retrieves a specific issue or pull request by its number
+func (c *GraphQLClient) GetIssue(owner, repo string, number int) (*Issue, error) {
+	query := `query($owner: String!, $name: String!, $number: Int!) { repository(owner: $owner, name: $name) { ` +
+		`issueOrPullRequest(number: $number) { ` +
+		`... on Issue { ` + gqlIssueSummaryFields + ` } ` +
+		`... on PullRequest { ` + gqlIssueSummaryFields + ` } } } }`
+	var data struct {
+		Repository struct {
+			IssueOrPullRequest *gqlIssue `json:"issueOrPullRequest"`
+		} `json:"repository"`
+	}
+	if err := c.query(query, map[string]any{"owner": owner, "name": repo, "number": number}, &data); err != nil {
+		return nil, err
+	}
+	if data.Repository.IssueOrPullRequest == nil {
+		return nil, fmt.Errorf("GitHub GraphQL API error: no issue %s/%s#%d", owner, repo, number)
+	}
+	issue := data.Repository.IssueOrPullRequest.issue()
+	return &issue, nil
+}
+
+// GetIssueDetails retrieves the comments and events of issue and, for a pull request, its commits and reviews
+func (c *GraphQLClient) GetIssueDetails(owner, repo string, issue Issue, since time.Time) (*IssueDetails, error) {
+	field, pr := "issue", issue.PullRequest != nil
+	if pr {
+		field = "pullRequest"
+	}
+	query := fmt.Sprintf(`query($owner: String!, $name: String!, $number: Int!) { repository(owner: $owner, name: $name) { %s(number: $number) { %s } } }`,
+		field, gqlIssueFields(pr))
+
+	var data struct {
+		Repository map[string]*gqlIssue `json:"repository"`
+	}
+	if err := c.query(query, map[string]any{"owner": owner, "name": repo, "number": issue.Number}, &data); err != nil {
+		return nil, err
+	}
+	found := data.Repository[field]
+	if found == nil {
+		return nil, fmt.Errorf("GitHub GraphQL API error: no issue %s/%s#%d", owner, repo, issue.Number)
+	}
+	return c.details(found, since)
+}
+
+// CountIssues returns how many issues and pull requests match a search query
+func (c *GraphQLClient) CountIssues(query string) (int, error) {
+	var data struct {
+		Search struct {
+			IssueCount int `json:"issueCount"`
+		} `json:"search"`
+	}
+	err := c.query(`query($q: String!) { search(query: $q, type: ISSUE, first: 1) { issueCount } }`, map[string]any{"q": query}, &data)
+	return data.Search.IssueCount, err
+}
+
+// details completes issue and converts it to the REST types
+func (c *GraphQLClient) details(issue *gqlIssue, since time.Time) (*IssueDetails, error) {
+	if err := c.complete(issue); err != nil {
+		return nil, err
+	}
+
+	details := &IssueDetails{Issue: issue.issue()}
+
+	// like the REST API's since parameter
+	for _, comment := range issue.Comments.Nodes {
+		if comment.UpdatedAt.Before(since) {
+			continue
+		}
+		converted := IssueComment{
+			ID:        comment.DatabaseID,
+			Body:      comment.Body,
+			CreatedAt: comment.CreatedAt,
+			UpdatedAt: comment.UpdatedAt,
+			HTMLURL:   comment.URL,
+			Reactions: gqlReactions(comment.ReactionGroups),
+		}
+		if comment.Author != nil {
+			converted.User.Login = comment.Author.Login
+			converted.User.AvatarURL = comment.Author.AvatarURL
+		}
+		details.Comments = append(details.Comments, converted)
+	}
+
+	events := map[string]string{}
+	for _, e := range timelineEvents {
+		events[e.typename] = e.event
+	}
+	for _, item := range issue.TimelineItems.Nodes {
+		event := IssueEvent{
+			NodeID:    item.ID,
+			Actor:     item.Actor.user(),
+			Event:     events[item.Typename],
+			CreatedAt: item.CreatedAt,
+		}
+		if item.Commit != nil {
+			event.CommitID = &item.Commit.OID
+			event.CommitURL = &item.Commit.URL
+		}
+		details.Events = append(details.Events, event)
+	}
+
+	if issue.Typename != "PullRequest" {
+		return details, nil
+	}
+
+	details.PR = &PullRequest{
+		Number:    issue.Number,
+		Title:     issue.Title,
+		State:     details.Issue.State,
+		Draft:     issue.IsDraft,
+		Merged:    issue.Merged,
+		CreatedAt: issue.CreatedAt,
+		UpdatedAt: issue.UpdatedAt,
+		MergedAt:  issue.MergedAt,
+		HTMLURL:   issue.URL,
+	}
+	details.PR.User.Login = details.Issue.User.Login
+
+	for _, node := range issue.Commits.Nodes {
+		commit := PullRequestCommit{
+			SHA:       node.Commit.OID,
+			HTMLURL:   node.Commit.URL,
+			Author:    node.Commit.Author.User.user(),
+			Committer: node.Commit.Committer.User.user(),
+		}
+		commit.Commit.Message = node.Commit.Message
+		commit.Commit.Author.Name = node.Commit.Author.Name
+		commit.Commit.Author.Email = node.Commit.Author.Email
+		commit.Commit.Author.Date = node.Commit.Author.Date
+		commit.Commit.Committer.Name = node.Commit.Committer.Name
+		commit.Commit.Committer.Email = node.Commit.Committer.Email
+		commit.Commit.Committer.Date = node.Commit.Committer.Date
+		commit.Commit.Verification.Reason = "unsigned"
+		if node.Commit.Signature != nil {
+			commit.Commit.Verification.Verified = node.Commit.Signature.IsValid
+			commit.Commit.Verification.Reason = strings.ToLower(node.Commit.Signature.State)
+		}
+		details.Commits = append(details.Commits, commit)
+	}
+
+	for _, node := range issue.Reviews.Nodes {
+		review := PullRequestReview{
+			ID:                node.DatabaseID,
+			User:              node.Author.user(),
+			Body:              node.Body,
+			State:             node.State,
+			HTMLURL:           node.URL,
+			SubmittedAt:       node.SubmittedAt,
+			AuthorAssociation: node.AuthorAssociation,
+		}
+		if node.Commit != nil {
+			review.CommitID = node.Commit.OID
+		}
+		details.Reviews = append(details.Reviews, review)
+	}
+	return details, nil
+}
+
+// issue converts to the REST type; merged pull requests are "closed" there
+func (i *gqlIssue) issue() Issue {
+	issue := Issue{
+		Number:    i.Number,
+		Title:     i.Title,
+		State:     strings.ToLower(i.State),
+		CreatedAt: i.CreatedAt,
+		UpdatedAt: i.UpdatedAt,
+		ClosedAt:  i.ClosedAt,
+		HTMLURL:   i.URL,
+		Reactions: gqlReactions(i.ReactionGroups),
+	}
+	if issue.State == "merged" {
+		issue.State = "closed"
+	}
+	if i.Author != nil {
+		issue.User.Login = i.Author.Login
+	} else {
+		issue.User.Login = "ghost" // deleted accounts
+	}
+	if i.Typename == "PullRequest" {
+		issue.PullRequest = &struct{}{}
+	}
+	return issue
+}
+
+// user converts to the REST type
+func (a *gqlActor) user() *User {
+	if a == nil {
+		return nil
+	}
+	return &User{Login: a.Login, AvatarURL: a.AvatarURL, HTMLURL: a.URL}
+}
+
+// nullable is nil for an empty string, so GraphQL sees null
+func nullable(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// sortByUpdated orders issues and pull requests together, most recently updated first, like the REST API
+func sortByUpdated(all []IssueDetails) []IssueDetails {
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].Issue.UpdatedAt.After(all[j].Issue.UpdatedAt)
+	})
+	return all
+}
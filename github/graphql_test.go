@@ -0,0 +1,291 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// graphQLRequest is the body of a request to the GraphQL API
+type graphQLRequest struct {
+	Query     string         `json:"query"`
+	Variables map[string]any `json:"variables"`
+}
+
+// graphQLServer stands in for the GraphQL API, answering each request with the data that answer returns
+type graphQLServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []graphQLRequest
+}
+
+func newGraphQLServer(t *testing.T, answer func(req graphQLRequest) string) *graphQLServer {
+	s := &graphQLServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/graphql" {
+			t.Errorf("%s %s, want POST /graphql", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer test-token" {
+			t.Errorf("Authorization %q", got)
+		}
+		var req graphQLRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("request body: %v", err)
+		}
+		s.mu.Lock()
+		s.requests = append(s.requests, req)
+		s.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"data": %s}`, answer(req))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// count is how many requests had a query containing substr
+func (s *graphQLServer) count(substr string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, req := range s.requests {
+		if strings.Contains(req.Query, substr) {
+			n++
+		}
+	}
+	return n
+}
+
+func newTestGraphQLClient(server *graphQLServer) *GraphQLClient {
+	client := NewGraphQLClient(Options{Token: "test-token", BaseURL: server.URL})
+	client.SetMinInterval(0)
+	return client
+}
+
+const noMorePages = `"pageInfo": {"hasNextPage": false, "endCursor": null}`
+
+var testSince = time.Date(2025, 6, 2, 12, 0, 0, 0, time.UTC)
+
+// recentAnswer has an issue whose comments take two pages, and pages of pull requests
+// of which the second ends with one updated before testSince, so no third should be requested
+func recentAnswer(t *testing.T) func(req graphQLRequest) string {
+	return func(req graphQLRequest) string {
+		after, _ := req.Variables["after"].(string)
+		switch {
+		case strings.Contains(req.Query, "issues(first:"):
+			if req.Variables["since"] != "2025-06-02T12:00:00Z" {
+				t.Errorf("issues since %v", req.Variables["since"])
+			}
+			return `{"repository": {"issues": {"nodes": [{
+				"__typename": "Issue", "id": "I_2", "number": 2, "title": "Crash", "state": "OPEN",
+				"createdAt": "2025-06-01T09:00:00Z", "updatedAt": "2025-06-03T10:00:00Z", "url": "https://github.com/o/r/issues/2",
+				"author": {"login": "dave"},
+				"comments": {"nodes": [
+					{"databaseId": 1, "body": "old", "createdAt": "2025-06-01T10:00:00Z", "updatedAt": "2025-06-01T10:00:00Z", "author": {"login": "erin"}},
+					{"databaseId": 2, "body": "new", "createdAt": "2025-06-03T09:00:00Z", "updatedAt": "2025-06-03T09:00:00Z", "author": {"login": "frank", "avatarUrl": "https://avatars/frank"}}
+				], "pageInfo": {"hasNextPage": true, "endCursor": "comments-1"}},
+				"timelineItems": {"nodes": [
+					{"__typename": "LabeledEvent", "id": "LE_1", "createdAt": "2025-06-03T08:00:00Z", "actor": {"login": "carol", "url": "https://github.com/carol"}},
+					{"__typename": "ReferencedEvent", "id": "RE_1", "createdAt": "2025-06-03T08:30:00Z", "actor": {"login": "alice"},
+					 "commit": {"oid": "abc1234", "url": "https://github.com/o/r/commit/abc1234"}}
+				], ` + noMorePages + `}
+			}], ` + noMorePages + `}}}`
+
+		case strings.Contains(req.Query, "pullRequests(first:"):
+			switch after {
+			case "":
+				return `{"repository": {"pullRequests": {"nodes": [` + testPR(10, "MERGED", "2025-06-04T09:00:00Z", false) +
+					`], "pageInfo": {"hasNextPage": true, "endCursor": "prs-1"}}}}`
+			case "prs-1":
+				return `{"repository": {"pullRequests": {"nodes": [` +
+					testPR(9, "OPEN", "2025-06-03T09:00:00Z", true) + `, ` +
+					testPR(8, "OPEN", "2025-06-01T09:00:00Z", false) +
+					`], "pageInfo": {"hasNextPage": true, "endCursor": "prs-2"}}}}`
+			}
+			t.Errorf("pull requests after %q, which is past since", after)
+			return `{"repository": {"pullRequests": {"nodes": [], ` + noMorePages + `}}}`
+
+		case strings.Contains(req.Query, "node(id: $id)") && strings.Contains(req.Query, "comments(first:"):
+			if req.Variables["id"] != "I_2" || after != "comments-1" {
+				t.Errorf("comments of %v after %v", req.Variables["id"], req.Variables["after"])
+			}
+			return `{"node": {"comments": {"nodes": [
+				{"databaseId": 3, "body": "newer", "createdAt": "2025-06-03T10:00:00Z", "updatedAt": "2025-06-03T10:00:00Z", "author": null}
+			], ` + noMorePages + `}}}`
+
+		case strings.Contains(req.Query, "node(id: $id)") && strings.Contains(req.Query, "reviews(first:"):
+			if req.Variables["id"] != "PR_9" || after != "reviews-1" {
+				t.Errorf("reviews of %v after %v", req.Variables["id"], req.Variables["after"])
+			}
+			return `{"node": {"reviews": {"nodes": [
+				{"databaseId": 902, "author": {"login": "bob"}, "state": "APPROVED", "submittedAt": "2025-06-03T11:00:00Z"}
+			], ` + noMorePages + `}}}`
+		}
+		t.Errorf("unexpected query %s", req.Query)
+		return `null`
+	}
+}
+
+// testPR is a pull request with one commit and one review, and more reviews on another page if moreReviews
+func testPR(number int, state, updatedAt string, moreReviews bool) string {
+	reviewsPage := noMorePages
+	if moreReviews {
+		reviewsPage = `"pageInfo": {"hasNextPage": true, "endCursor": "reviews-1"}`
+	}
+	merged := state == "MERGED"
+	return fmt.Sprintf(`{
+		"__typename": "PullRequest", "id": "PR_%[1]d", "number": %[1]d, "title": "PR %[1]d", "state": %[2]q,
+		"createdAt": "2025-05-30T09:00:00Z", "updatedAt": %[3]q, "url": "https://github.com/o/r/pull/%[1]d",
+		"author": {"login": "alice"}, "isDraft": false, "merged": %[4]t,
+		"comments": {"nodes": [], %[5]s},
+		"timelineItems": {"nodes": [
+			{"__typename": "MergedEvent", "id": "ME_%[1]d", "createdAt": %[3]q, "actor": {"login": "carol"},
+			 "commit": {"oid": "fedcba9", "url": "https://github.com/o/r/commit/fedcba9"}}
+		], %[5]s},
+		"commits": {"nodes": [{"commit": {"oid": "1111111", "message": "Do it", "url": "https://github.com/o/r/commit/1111111",
+			"author": {"name": "Alice", "email": "alice@example.com", "date": "2025-05-30T08:00:00Z", "user": {"login": "alice"}},
+			"committer": {"name": "GitHub", "email": "noreply@github.com", "date": "2025-05-30T08:00:00Z", "user": null},
+			"signature": {"isValid": true, "state": "VALID"}}}], %[5]s},
+		"reviews": {"nodes": [{"databaseId": %[1]d01, "author": {"login": "bob"}, "body": "Needs a test", "state": "CHANGES_REQUESTED",
+			"url": "https://github.com/o/r/pull/%[1]d#pullrequestreview-%[1]d01", "submittedAt": "2025-06-02T15:00:00Z",
+			"authorAssociation": "MEMBER", "commit": {"oid": "1111111"}}], %[6]s}
+	}`, number, state, updatedAt, merged, noMorePages, reviewsPage)
+}
+
+func TestGraphQLRecentIssueDetails(t *testing.T) {
+	server := newGraphQLServer(t, recentAnswer(t))
+	all, err := newTestGraphQLClient(server).GetRecentIssueDetails("o", "r", testSince)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var numbers []int
+	for _, details := range all {
+		numbers = append(numbers, details.Issue.Number)
+	}
+	if fmt.Sprint(numbers) != "[10 2 9]" {
+		t.Fatalf("got %v, want [10 2 9]: most recently updated first and none updated before since", numbers)
+	}
+	if n := server.count("pullRequests(first:"); n != 2 {
+		t.Errorf("%d pages of pull requests requested, want 2: paging stops at the first one updated before since", n)
+	}
+
+	pr, issue, pr9 := all[0], all[1], all[2]
+
+	// comments from both pages, except the one not updated since
+	var bodies []string
+	for _, comment := range issue.Comments {
+		bodies = append(bodies, comment.Body)
+	}
+	if fmt.Sprint(bodies) != "[new newer]" {
+		t.Errorf("comments %v, want [new newer]", bodies)
+	}
+	if c := issue.Comments[0]; c.ID != 2 || c.User.Login != "frank" || c.User.AvatarURL != "https://avatars/frank" {
+		t.Errorf("comment %+v", c)
+	}
+	if issue.PR != nil || issue.Issue.PullRequest != nil || issue.Issue.State != "open" {
+		t.Errorf("issue 2 is %q, with pull request %v", issue.Issue.State, issue.PR)
+	}
+
+	// timeline items become REST events
+	if len(issue.Events) != 2 {
+		t.Fatalf("%d events, want 2", len(issue.Events))
+	}
+	labeled, referenced := issue.Events[0], issue.Events[1]
+	if labeled.Event != "labeled" || labeled.NodeID != "LE_1" || labeled.Actor.Login != "carol" || labeled.Actor.HTMLURL != "https://github.com/carol" ||
+		!labeled.CreatedAt.Equal(time.Date(2025, 6, 3, 8, 0, 0, 0, time.UTC)) || labeled.CommitID != nil {
+		t.Errorf("labeled event %+v", labeled)
+	}
+	if referenced.Event != "referenced" || referenced.CommitID == nil || *referenced.CommitID != "abc1234" ||
+		*referenced.CommitURL != "https://github.com/o/r/commit/abc1234" {
+		t.Errorf("referenced event %+v", referenced)
+	}
+
+	// merged pull requests are closed in REST
+	if pr.Issue.State != "closed" || pr.Issue.PullRequest == nil || pr.PR == nil || !pr.PR.Merged || pr.PR.User.Login != "alice" {
+		t.Errorf("pull request 10 is %q with %+v", pr.Issue.State, pr.PR)
+	}
+	if len(pr.Events) != 1 || pr.Events[0].Event != "merged" || *pr.Events[0].CommitID != "fedcba9" {
+		t.Errorf("pull request 10 events %+v", pr.Events)
+	}
+	if len(pr.Commits) != 1 {
+		t.Fatalf("%d commits, want 1", len(pr.Commits))
+	}
+	commit := pr.Commits[0]
+	if commit.SHA != "1111111" || commit.Author.Login != "alice" || commit.Committer != nil ||
+		!commit.Commit.Verification.Verified || commit.Commit.Verification.Reason != "valid" {
+		t.Errorf("commit %+v", commit)
+	}
+
+	// reviews map to PullRequestReview, from every page
+	if len(pr.Reviews) != 1 {
+		t.Fatalf("%d reviews of pull request 10, want 1", len(pr.Reviews))
+	}
+	review := pr.Reviews[0]
+	if review.ID != 1001 || review.User.Login != "bob" || review.State != "CHANGES_REQUESTED" || review.Body != "Needs a test" ||
+		review.CommitID != "1111111" || review.AuthorAssociation != "MEMBER" || review.HTMLURL != "https://github.com/o/r/pull/10#pullrequestreview-1001" ||
+		review.SubmittedAt == nil || !review.SubmittedAt.Equal(time.Date(2025, 6, 2, 15, 0, 0, 0, time.UTC)) {
+		t.Errorf("review %+v", review)
+	}
+	if len(pr9.Reviews) != 2 || pr9.Reviews[1].ID != 902 || pr9.Reviews[1].State != "APPROVED" {
+		t.Errorf("reviews of pull request 9 %+v, want 2 with the second from another page", pr9.Reviews)
+	}
+}
+
+func TestGraphQLMorePages(t *testing.T) {
+	pages := map[string]string{
+		"a": `{"node": {"comments": {"nodes": [{"databaseId": 2}], "pageInfo": {"hasNextPage": true, "endCursor": "b"}}}}`,
+		"b": `{"node": {"comments": {"nodes": [{"databaseId": 3}, {"databaseId": 4}], ` + noMorePages + `}}}`,
+	}
+	server := newGraphQLServer(t, func(req graphQLRequest) string {
+		if !strings.Contains(req.Query, "... on Issue { comments(first: 100, after: $after)") || req.Variables["id"] != "I_1" {
+			t.Errorf("query %s with %v", req.Query, req.Variables)
+		}
+		return pages[req.Variables["after"].(string)]
+	})
+
+	first := gqlConnection[gqlComment]{
+		Nodes:    []gqlComment{{DatabaseID: 1}},
+		PageInfo: gqlPageInfo{HasNextPage: true, EndCursor: "a"},
+	}
+	comments, err := morePages(newTestGraphQLClient(server), "I_1", "Issue", "comments", "", gqlCommentFields, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int
+	for _, c := range comments {
+		ids = append(ids, c.DatabaseID)
+	}
+	if fmt.Sprint(ids) != "[1 2 3 4]" {
+		t.Errorf("got %v, want [1 2 3 4]", ids)
+	}
+
+	// a complete first page needs no request
+	before := server.count("")
+	if _, err := morePages(newTestGraphQLClient(server), "I_1", "Issue", "comments", "", gqlCommentFields, gqlConnection[gqlComment]{}); err != nil {
+		t.Fatal(err)
+	}
+	if server.count("") != before {
+		t.Error("requested more of a connection without more pages")
+	}
+}
+
+func TestGraphQLErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data": null, "errors": [{"message": "Could not resolve to a Repository with the name 'o/missing'."}]}`)
+	}))
+	defer server.Close()
+
+	client := NewGraphQLClient(Options{BaseURL: server.URL})
+	client.SetMinInterval(0)
+	_, err := client.GetRecentIssueDetails("o", "missing", testSince)
+	if err == nil || !strings.Contains(err.Error(), "Could not resolve") {
+		t.Errorf("error %v", err)
+	}
+}
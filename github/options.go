@@ -0,0 +1,111 @@
+package github
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultAPIVersion is the REST API version requested unless Options.APIVersion says otherwise
+const DefaultAPIVersion = "2022-11-28"
+
+// DefaultUserAgent identifies the dashboard to GitHub
+const DefaultUserAgent = "cwpearson/kokkos-dashboard"
+
+// Options configures a Client or a GraphQLClient. The zero value talks to api.github.com unauthenticated.
+type Options struct {
+	// Token is a personal access token, or empty for unauthenticated requests
+	Token string
+	// Auth authenticates requests instead of Token, e.g. an *AppAuth
+	Auth Auth
+	// BaseURL is the root of the REST API: DefaultBaseURL, or https://HOST/api/v3 for GitHub Enterprise Server
+	BaseURL string
+	// GraphQLURL is the GraphQL API, if not the one GraphQLEndpoint derives from BaseURL
+	GraphQLURL string
+	// APIVersion is sent as X-GitHub-Api-Version, DefaultAPIVersion if empty
+	APIVersion string
+	// UserAgent is DefaultUserAgent if empty
+	UserAgent string
+	// HTTPClient makes the requests; if nil, a client with a 30s timeout and Transport
+	HTTPClient *http.Client
+	// Transport is http.DefaultTransport if nil; ignored if HTTPClient is set
+	Transport http.RoundTripper
+}
+
+// withDefaults fills in the empty fields of o
+func (o Options) withDefaults() Options {
+	if o.Auth == nil {
+		o.Auth = TokenAuth(o.Token)
+	}
+	if o.BaseURL == "" {
+		o.BaseURL = DefaultBaseURL
+	}
+	o.BaseURL = strings.TrimSuffix(o.BaseURL, "/")
+	if o.APIVersion == "" {
+		o.APIVersion = DefaultAPIVersion
+	}
+	if o.UserAgent == "" {
+		o.UserAgent = DefaultUserAgent
+	}
+	if o.HTTPClient == nil {
+		o.HTTPClient = &http.Client{Timeout: 30 * time.Second, Transport: o.Transport}
+	}
+	return o
+}
+
+// GraphQLEndpoint is GraphQLURL or else the GraphQL API next to the REST API at BaseURL:
+// /api/graphql on GitHub Enterprise Server, /graphql elsewhere
+func (o Options) GraphQLEndpoint() string {
+	if o.GraphQLURL != "" {
+		return o.GraphQLURL
+	}
+	base := o.withDefaults().BaseURL
+	if root, ok := strings.CutSuffix(base, "/api/v3"); ok {
+		return root + "/api/graphql"
+	}
+	return base + "/graphql"
+}
+
+// WebURL is the web interface of the GitHub whose REST API is at BaseURL: https://github.com for api.github.com,
+// https://HOST for GitHub Enterprise Server at https://HOST/api/v3, and BaseURL itself for anything else, like a mock
+func (o Options) WebURL() string {
+	base := o.withDefaults().BaseURL
+	if root, ok := strings.CutSuffix(base, "/api/v3"); ok {
+		return root
+	}
+	if u, err := url.Parse(base); err == nil && u.Path == "" {
+		if host, ok := strings.CutPrefix(u.Host, "api."); ok {
+			u.Host = host
+			return u.String()
+		}
+	}
+	return base
+}
+
+// minInterval spreads requests evenly over the hour the rate limit of o.Auth covers
+func (o Options) minInterval() time.Duration {
+	return time.Hour / time.Duration(o.Auth.RequestsPerHour())
+}
+
+// setHeaders sets the headers every request to the API carries
+func (o Options) setHeaders(req *http.Request) error {
+	authorization, err := o.Auth.Authorization()
+	if err != nil {
+		return err
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", o.APIVersion)
+	req.Header.Set("User-Agent", o.UserAgent)
+	return nil
+}
+
+// observe tells o.Auth about the rate limit in the response to req, if it keeps track
+func (o Options) observe(req *http.Request, resp *http.Response) {
+	if observer, ok := o.Auth.(rateLimitObserver); ok {
+		observer.observe(req.Header.Get("Authorization"), resp)
+	}
+}
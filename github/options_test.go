@@ -0,0 +1,157 @@
+package github
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestWebURL(t *testing.T) {
+	for base, want := range map[string]string{
+		"":                                   "https://github.com",
+		DefaultBaseURL:                       "https://github.com",
+		"https://api.github.com/":            "https://github.com",
+		"https://ghes.example.com/api/v3":    "https://ghes.example.com",
+		"https://ghes.example.com/api/v3/":   "https://ghes.example.com",
+		"https://api.octocorp.ghe.com":       "https://octocorp.ghe.com",
+		"http://127.0.0.1:8080":              "http://127.0.0.1:8080",
+		"http://localhost:9000/github-proxy": "http://localhost:9000/github-proxy",
+	} {
+		if got := (Options{BaseURL: base}).WebURL(); got != want {
+			t.Errorf("web URL of %q is %q, want %q", base, got, want)
+		}
+	}
+}
+
+func TestGraphQLEndpoint(t *testing.T) {
+	for _, test := range []struct {
+		opts Options
+		want string
+	}{
+		{Options{}, "https://api.github.com/graphql"},
+		{Options{BaseURL: "https://api.github.com/"}, "https://api.github.com/graphql"},
+		{Options{BaseURL: "https://ghes.example.com/api/v3"}, "https://ghes.example.com/api/graphql"},
+		{Options{BaseURL: "https://ghes.example.com/api/v3/"}, "https://ghes.example.com/api/graphql"},
+		{Options{BaseURL: "http://127.0.0.1:8080"}, "http://127.0.0.1:8080/graphql"},
+		{Options{BaseURL: "https://ghes.example.com/api/v3", GraphQLURL: "https://proxy.example.com/gql"}, "https://proxy.example.com/gql"},
+	} {
+		if got := test.opts.GraphQLEndpoint(); got != test.want {
+			t.Errorf("GraphQL endpoint of %+v is %q, want %q", test.opts, got, test.want)
+		}
+	}
+}
+
+// countServer answers searches of the REST and GraphQL APIs, at the paths of GitHub Enterprise Server,
+// and keeps the headers of each request
+type countServer struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	headers []http.Header
+}
+
+func newCountServer(t *testing.T) *countServer {
+	s := &countServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.headers = append(s.headers, r.Header.Clone())
+		s.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method + " " + r.URL.Path {
+		case "GET /api/v3/search/issues":
+			io.WriteString(w, `{"total_count": 3}`)
+		case "POST /api/graphql":
+			io.WriteString(w, `{"data": {"search": {"issueCount": 3}}}`)
+		default:
+			t.Errorf("%s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// clients are a REST and a GraphQL client with opts, which count issues the same way
+func clients(opts Options) map[string]interface{ CountIssues(string) (int, error) } {
+	rest := NewClientWithOptions(opts)
+	rest.SetMinInterval(0)
+	graphQL := NewGraphQLClient(opts)
+	graphQL.SetMinInterval(0)
+	return map[string]interface{ CountIssues(string) (int, error) }{"REST": rest, "GraphQL": graphQL}
+}
+
+func TestRequestHeaders(t *testing.T) {
+	for _, test := range []struct {
+		opts                                 Options
+		authorization, apiVersion, userAgent string
+	}{
+		{Options{}, "", DefaultAPIVersion, DefaultUserAgent},
+		{Options{Token: "ghp_test", APIVersion: "2026-03-10", UserAgent: "kokkos-dashboard-test"}, "Bearer ghp_test", "2026-03-10", "kokkos-dashboard-test"},
+	} {
+		server := newCountServer(t)
+		test.opts.BaseURL = server.URL + "/api/v3"
+		for name, client := range clients(test.opts) {
+			if n, err := client.CountIssues("repo:kokkos/kokkos is:open"); err != nil || n != 3 {
+				t.Errorf("%s: %d, %v", name, n, err)
+			}
+		}
+
+		if len(server.headers) != 2 {
+			t.Fatalf("%d requests, want 2", len(server.headers))
+		}
+		for _, header := range server.headers {
+			for name, want := range map[string]string{
+				"Authorization":        test.authorization,
+				"Accept":               "application/vnd.github+json",
+				"X-GitHub-Api-Version": test.apiVersion,
+				"User-Agent":           test.userAgent,
+			} {
+				if got := header.Get(name); got != want {
+					t.Errorf("%s: %q, want %q", name, got, want)
+				}
+			}
+		}
+	}
+}
+
+// countingTransport is an http.RoundTripper that counts the requests it passes to http.DefaultTransport
+type countingTransport struct {
+	mu sync.Mutex
+	n  int
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	c.n++
+	c.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestInjectedHTTP(t *testing.T) {
+	server := newCountServer(t)
+
+	transport := &countingTransport{}
+	for name, client := range clients(Options{BaseURL: server.URL + "/api/v3", Transport: transport}) {
+		if _, err := client.CountIssues("is:open"); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if transport.n != 2 {
+		t.Errorf("Transport made %d requests, want 2", transport.n)
+	}
+
+	// HTTPClient wins over Transport
+	used, ignored := &countingTransport{}, &countingTransport{}
+	opts := Options{BaseURL: server.URL + "/api/v3", HTTPClient: &http.Client{Transport: used}, Transport: ignored}
+	for name, client := range clients(opts) {
+		if _, err := client.CountIssues("is:open"); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if used.n != 2 || ignored.n != 0 {
+		t.Errorf("HTTPClient made %d requests and Transport %d, want 2 and 0", used.n, ignored.n)
+	}
+}
@@ -0,0 +1,168 @@
+package github
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// rateLimitObserver is an Auth that learns from the rate limit headers of the responses to its requests
+type rateLimitObserver interface {
+	observe(authorization string, resp *http.Response)
+}
+
+// Pool spreads requests over several credentials.
+// Each request goes to the credential with the most requests left, going by the X-RateLimit headers of
+// its last response, or, when all are used up, to the one whose limit resets first.
+// Only the core quota counts: search and GraphQL have separate, smaller ones.
+type Pool struct {
+	mu      sync.Mutex
+	members []*poolMember
+}
+
+type poolMember struct {
+	name          string
+	auth          Auth
+	authorization string // sent with the last request
+
+	requests  int
+	known     bool // whether a response reported remaining, limit and reset
+	remaining int
+	limit     int
+	reset     time.Time
+}
+
+// CredentialUsage is how much of a credential's quota a Pool has used
+type CredentialUsage struct {
+	Name      string
+	Requests  int       // made with the credential
+	Remaining int       // of Limit, -1 before the first response
+	Limit     int       // per hour
+	Reset     time.Time // when Remaining goes back to Limit
+}
+
+// NewPool creates an empty pool; Add credentials before making requests with it
+func NewPool() *Pool {
+	return &Pool{}
+}
+
+// Add puts auth in the pool; name identifies it in Usage
+func (p *Pool) Add(name string, auth Auth) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.members = append(p.members, &poolMember{name: name, auth: auth})
+}
+
+// headroom is how many requests m has left, assuming its full hourly limit until a response says otherwise
+func (m *poolMember) headroom(now time.Time) int {
+	if !m.known || !now.Before(m.reset) {
+		return m.auth.RequestsPerHour()
+	}
+	return m.remaining
+}
+
+// next picks the credential for the next request
+func (p *Pool) next() *poolMember {
+	now := time.Now()
+	var best *poolMember
+	for _, m := range p.members {
+		if best == nil {
+			best = m
+			continue
+		}
+		mHeadroom, bestHeadroom := m.headroom(now), best.headroom(now)
+		if mHeadroom > bestHeadroom || mHeadroom == 0 && bestHeadroom == 0 && m.reset.Before(best.reset) {
+			best = m
+		}
+	}
+	return best
+}
+
+func (p *Pool) Authorization() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	m := p.next()
+	if m == nil {
+		return "", nil
+	}
+	authorization, err := m.auth.Authorization()
+	if err != nil {
+		return "", err
+	}
+	m.authorization = authorization
+	m.requests++
+	return authorization, nil
+}
+
+// RequestsPerHour is the sum over the pool
+func (p *Pool) RequestsPerHour() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	total := 0
+	for _, m := range p.members {
+		total += m.auth.RequestsPerHour()
+	}
+	if total == 0 {
+		return TokenAuth("").RequestsPerHour()
+	}
+	return total
+}
+
+// coreResource is the X-RateLimit-Resource of the REST API quota; responses without the header count against it
+const coreResource = "core"
+
+func (p *Pool) observe(authorization string, resp *http.Response) {
+	if resource := resp.Header.Get("X-RateLimit-Resource"); resource != "" && resource != coreResource {
+		return
+	}
+	remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	limit, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Limit"))
+	if err != nil {
+		return
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, m := range p.members {
+		if m.authorization == authorization {
+			m.known = true
+			m.remaining = remaining
+			m.limit = limit
+			m.reset = time.Unix(reset, 0)
+			return
+		}
+	}
+}
+
+// Usage reports each credential, in the order they were added
+func (p *Pool) Usage() []CredentialUsage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var usage []CredentialUsage
+	for _, m := range p.members {
+		u := CredentialUsage{
+			Name:      m.name,
+			Requests:  m.requests,
+			Remaining: -1,
+			Limit:     m.auth.RequestsPerHour(),
+		}
+		if m.known {
+			u.Remaining = m.remaining
+			u.Limit = m.limit
+			u.Reset = m.reset
+		}
+		usage = append(usage, u)
+	}
+	return usage
+}
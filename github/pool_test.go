@@ -0,0 +1,119 @@
+package github
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// rateLimitResponse has the rate limit headers GitHub sends for resource
+func rateLimitResponse(resource string, remaining, limit int, reset time.Time) *http.Response {
+	header := http.Header{}
+	header.Set("X-RateLimit-Resource", resource)
+	header.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	header.Set("X-RateLimit-Limit", strconv.Itoa(limit))
+	header.Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+	return &http.Response{Header: header}
+}
+
+func TestPoolObservesCoreOnly(t *testing.T) {
+	pool := NewPool()
+	pool.Add("a", TokenAuth("a"))
+	pool.Add("b", TokenAuth("b"))
+	reset := time.Now().Add(30 * time.Minute)
+
+	next := func() string {
+		t.Helper()
+		authorization, err := pool.Authorization()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return authorization
+	}
+
+	// both untouched: the first
+	if got := next(); got != "Bearer a" {
+		t.Fatalf("first request with %q, want a", got)
+	}
+	pool.observe("Bearer a", rateLimitResponse("core", 100, 5000, reset))
+
+	// b still has its full hour
+	if got := next(); got != "Bearer b" {
+		t.Fatalf("second request with %q, want b", got)
+	}
+	// a search response says little is left, but that is the search quota
+	pool.observe("Bearer b", rateLimitResponse("search", 2, 30, reset))
+	if got := next(); got != "Bearer b" {
+		t.Fatalf("request after a search with %q, want b", got)
+	}
+	pool.observe("Bearer b", rateLimitResponse("core", 4000, 5000, reset))
+	pool.observe("Bearer a", rateLimitResponse("graphql", 4999, 5000, reset))
+	pool.observe("Bearer a", rateLimitResponse("search", 29, 30, reset))
+	if got := next(); got != "Bearer b" {
+		t.Fatalf("request after core responses with %q, want b", got)
+	}
+
+	usage := pool.Usage()
+	want := []CredentialUsage{
+		{Name: "a", Requests: 1, Remaining: 100, Limit: 5000},
+		{Name: "b", Requests: 3, Remaining: 4000, Limit: 5000},
+	}
+	for i, u := range usage {
+		if u.Name != want[i].Name || u.Requests != want[i].Requests || u.Remaining != want[i].Remaining || u.Limit != want[i].Limit {
+			t.Errorf("usage %d is %+v, want %+v", i, u, want[i])
+		}
+	}
+}
+
+func TestPoolObservesResponsesWithoutResource(t *testing.T) {
+	pool := NewPool()
+	pool.Add("a", TokenAuth("a"))
+	if _, err := pool.Authorization(); err != nil {
+		t.Fatal(err)
+	}
+	resp := rateLimitResponse("", 10, 5000, time.Now().Add(time.Hour))
+	resp.Header.Del("X-RateLimit-Resource")
+	pool.observe("Bearer a", resp)
+	if got := pool.Usage()[0].Remaining; got != 10 {
+		t.Errorf("remaining %d, want 10", got)
+	}
+}
+
+func TestPoolFallsBackToEarliestReset(t *testing.T) {
+	pool := NewPool()
+	for _, name := range []string{"a", "b", "c"} {
+		pool.Add(name, TokenAuth(name))
+	}
+	now := time.Now()
+	resets := map[string]time.Time{
+		"Bearer a": now.Add(40 * time.Minute),
+		"Bearer b": now.Add(10 * time.Minute),
+		"Bearer c": now.Add(25 * time.Minute),
+	}
+
+	// each credential makes a request and reports its quota used up
+	for range resets {
+		authorization, err := pool.Authorization()
+		if err != nil {
+			t.Fatal(err)
+		}
+		pool.observe(authorization, rateLimitResponse("core", 0, 5000, resets[authorization]))
+	}
+	for i := range 3 {
+		authorization, err := pool.Authorization()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if authorization != "Bearer b" {
+			t.Fatalf("request %d with all exhausted went to %q, want b, which resets first", i, authorization)
+		}
+		pool.observe(authorization, rateLimitResponse("core", 0, 5000, resets[authorization]))
+	}
+
+	// once c's window has passed, it has its full hour again
+	pool.observe("Bearer c", rateLimitResponse("core", 0, 5000, now.Add(-time.Second)))
+	if authorization, err := pool.Authorization(); err != nil || authorization != "Bearer c" {
+		t.Errorf("request after c reset went to %q (%v), want c", authorization, err)
+	}
+}
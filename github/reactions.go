@@ -0,0 +1,88 @@
+package github
+
+import (
+	"net/url"
+	"strconv"
+)
+
+// Reactions counts the reactions to an issue, pull request or comment
+type Reactions struct {
+	TotalCount int `json:"total_count"`
+	PlusOne    int `json:"+1"`
+	MinusOne   int `json:"-1"`
+	Laugh      int `json:"laugh"`
+	Hooray     int `json:"hooray"`
+	Confused   int `json:"confused"`
+	Heart      int `json:"heart"`
+	Rocket     int `json:"rocket"`
+	Eyes       int `json:"eyes"`
+}
+
+const gqlReactionFields = `reactionGroups { content reactors { totalCount } }`
+
+type gqlReactionGroup struct {
+	Content  string `json:"content"`
+	Reactors struct {
+		TotalCount int `json:"totalCount"`
+	} `json:"reactors"`
+}
+
+// gqlReactions converts reaction groups to the REST type
+func gqlReactions(groups []gqlReactionGroup) Reactions {
+	var r Reactions
+	counts := map[string]*int{
+		"THUMBS_UP":   &r.PlusOne,
+		"THUMBS_DOWN": &r.MinusOne,
+		"LAUGH":       &r.Laugh,
+		"HOORAY":      &r.Hooray,
+		"CONFUSED":    &r.Confused,
+		"HEART":       &r.Heart,
+		"ROCKET":      &r.Rocket,
+		"EYES":        &r.Eyes,
+	}
+	for _, group := range groups {
+		if count, ok := counts[group.Content]; ok {
+			*count = group.Reactors.TotalCount
+			r.TotalCount += group.Reactors.TotalCount
+		}
+	}
+	return r
+}
+
+// GetMostUpvotedIssues retrieves the n open issues with the most 👍 reactions
+func (c *Client) GetMostUpvotedIssues(owner, repo string, n int) ([]Issue, error) {
+	var result struct {
+		Items []Issue `json:"items"`
+	}
+	query := url.Values{
+		"q":        {OpenIssuesQuery(owner, repo)},
+		"sort":     {"reactions-+1"},
+		"order":    {"desc"},
+		"per_page": {strconv.Itoa(n)},
+	}
+	if err := c.get("/search/issues", query, &result); err != nil {
+		return nil, err
+	}
+	return result.Items, nil
+}
+
+// GetMostUpvotedIssues retrieves the n open issues with the most 👍 reactions
+func (c *GraphQLClient) GetMostUpvotedIssues(owner, repo string, n int) ([]Issue, error) {
+	query := `query($q: String!, $n: Int!) { search(query: $q, type: ISSUE, first: $n) { nodes { ... on Issue { ` +
+		gqlIssueSummaryFields + ` } } } }`
+
+	var data struct {
+		Search struct {
+			Nodes []gqlIssue `json:"nodes"`
+		} `json:"search"`
+	}
+	if err := c.query(query, map[string]any{"q": OpenIssuesQuery(owner, repo) + " sort:reactions-+1-desc", "n": n}, &data); err != nil {
+		return nil, err
+	}
+
+	issues := []Issue{}
+	for _, node := range data.Search.Nodes {
+		issues = append(issues, node.issue())
+	}
+	return issues, nil
+}
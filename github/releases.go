@@ -0,0 +1,212 @@
+package github
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Release is a published version of a repository
+type Release struct {
+	ID          int64          `json:"id"`
+	TagName     string         `json:"tag_name"`
+	Name        string         `json:"name"`
+	Body        string         `json:"body"` // release notes, in Markdown
+	Draft       bool           `json:"draft"`
+	Prerelease  bool           `json:"prerelease"`
+	CreatedAt   time.Time      `json:"created_at"`
+	PublishedAt *time.Time     `json:"published_at"`
+	HTMLURL     string         `json:"html_url"`
+	Author      *User          `json:"author"`
+	Assets      []ReleaseAsset `json:"assets"`
+}
+
+// ReleaseAsset is a file attached to a release
+type ReleaseAsset struct {
+	Name               string `json:"name"`
+	ContentType        string `json:"content_type"`
+	Size               int64  `json:"size"`
+	DownloadCount      int    `json:"download_count"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+}
+
+// Tag is a git tag and the commit it points to
+type Tag struct {
+	Name   string `json:"name"`
+	Commit struct {
+		SHA string `json:"sha"`
+		URL string `json:"url"`
+	} `json:"commit"`
+}
+
+// PublishedSince is true for a release published, and not a draft, since a time
+func (r Release) PublishedSince(since time.Time) bool {
+	return !r.Draft && r.PublishedAt != nil && !r.PublishedAt.Before(since)
+}
+
+// GetReleases retrieves the releases published since a given timestamp, newest first
+func (c *Client) GetReleases(owner, repo string, since time.Time) ([]Release, error) {
+	var releases []Release
+	perPage := 100
+
+	// releases come newest first by creation; one is published after it is created
+	for page := 1; ; page++ {
+		query := url.Values{
+			"page":     {strconv.Itoa(page)},
+			"per_page": {strconv.Itoa(perPage)},
+		}
+		var items []Release
+		if err := c.get(fmt.Sprintf("/repos/%s/%s/releases", owner, repo), query, &items); err != nil {
+			return nil, err
+		}
+		for _, release := range items {
+			if release.PublishedSince(since) {
+				releases = append(releases, release)
+			}
+		}
+		if len(items) < perPage || items[len(items)-1].CreatedAt.Before(since) {
+			return releases, nil
+		}
+	}
+}
+
+// GetTags retrieves the first 100 tags of a repository
+func (c *Client) GetTags(owner, repo string) ([]Tag, error) {
+	var tags []Tag
+	if err := c.get(fmt.Sprintf("/repos/%s/%s/tags", owner, repo), url.Values{"per_page": {"100"}}, &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+type gqlRelease struct {
+	DatabaseID   int64      `json:"databaseId"`
+	TagName      string     `json:"tagName"`
+	Name         string     `json:"name"`
+	Description  string     `json:"description"`
+	IsDraft      bool       `json:"isDraft"`
+	IsPrerelease bool       `json:"isPrerelease"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	PublishedAt  *time.Time `json:"publishedAt"`
+	URL          string     `json:"url"`
+	Author       *gqlActor  `json:"author"`
+	Assets       struct {
+		Nodes []struct {
+			Name          string `json:"name"`
+			ContentType   string `json:"contentType"`
+			Size          int64  `json:"size"`
+			DownloadCount int    `json:"downloadCount"`
+			DownloadURL   string `json:"downloadUrl"`
+		} `json:"nodes"`
+	} `json:"releaseAssets"`
+}
+
+// release converts r to the REST type
+func (r gqlRelease) release() Release {
+	release := Release{
+		ID:          r.DatabaseID,
+		TagName:     r.TagName,
+		Name:        r.Name,
+		Body:        r.Description,
+		Draft:       r.IsDraft,
+		Prerelease:  r.IsPrerelease,
+		CreatedAt:   r.CreatedAt,
+		PublishedAt: r.PublishedAt,
+		HTMLURL:     r.URL,
+		Author:      r.Author.user(),
+		Assets:      []ReleaseAsset{},
+	}
+	for _, asset := range r.Assets.Nodes {
+		release.Assets = append(release.Assets, ReleaseAsset{
+			Name:               asset.Name,
+			ContentType:        asset.ContentType,
+			Size:               asset.Size,
+			DownloadCount:      asset.DownloadCount,
+			BrowserDownloadURL: asset.DownloadURL,
+		})
+	}
+	return release
+}
+
+// GetReleases retrieves the releases published since a given timestamp, newest first
+func (c *GraphQLClient) GetReleases(owner, repo string, since time.Time) ([]Release, error) {
+	const query = `query($owner: String!, $name: String!, $cursor: String) {
+  repository(owner: $owner, name: $name) {
+    releases(first: 100, after: $cursor, orderBy: {field: CREATED_AT, direction: DESC}) {
+      nodes {
+        databaseId tagName name description isDraft isPrerelease createdAt publishedAt url
+        author { login avatarUrl url }
+        releaseAssets(first: 100) { nodes { name contentType size downloadCount downloadUrl } }
+      }
+      pageInfo { hasNextPage endCursor }
+    }
+  }
+}`
+
+	var releases []Release
+	variables := map[string]any{"owner": owner, "name": repo, "cursor": nil}
+	for {
+		var data struct {
+			Repository struct {
+				Releases gqlConnection[gqlRelease] `json:"releases"`
+			} `json:"repository"`
+		}
+		if err := c.query(query, variables, &data); err != nil {
+			return nil, err
+		}
+		nodes := data.Repository.Releases.Nodes
+		for _, node := range nodes {
+			if release := node.release(); release.PublishedSince(since) {
+				releases = append(releases, release)
+			}
+		}
+		pageInfo := data.Repository.Releases.PageInfo
+		if !pageInfo.HasNextPage || len(nodes) == 0 || nodes[len(nodes)-1].CreatedAt.Before(since) {
+			return releases, nil
+		}
+		variables["cursor"] = pageInfo.EndCursor
+	}
+}
+
+// GetTags retrieves the 100 tags of a repository with the newest commits
+func (c *GraphQLClient) GetTags(owner, repo string) ([]Tag, error) {
+	const query = `query($owner: String!, $name: String!) {
+  repository(owner: $owner, name: $name) {
+    refs(refPrefix: "refs/tags/", first: 100, orderBy: {field: TAG_COMMIT_DATE, direction: DESC}) {
+      nodes { name target { oid ... on Tag { target { oid } } } }
+    }
+  }
+}`
+
+	var data struct {
+		Repository struct {
+			Refs struct {
+				Nodes []struct {
+					Name   string `json:"name"`
+					Target struct {
+						OID    string `json:"oid"`
+						Target *struct {
+							OID string `json:"oid"`
+						} `json:"target"` // of an annotated tag
+					} `json:"target"`
+				} `json:"nodes"`
+			} `json:"refs"`
+		} `json:"repository"`
+	}
+	if err := c.query(query, map[string]any{"owner": owner, "name": repo}, &data); err != nil {
+		return nil, err
+	}
+
+	tags := []Tag{}
+	for _, node := range data.Repository.Refs.Nodes {
+		var tag Tag
+		tag.Name = node.Name
+		tag.Commit.SHA = node.Target.OID
+		if node.Target.Target != nil {
+			tag.Commit.SHA = node.Target.Target.OID
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
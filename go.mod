@@ -2,12 +2,21 @@ module kokkos-dashboard
 
 go 1.24.3
 
-require github.com/gomarkdown/markdown v0.0.0-20250810172220-2e2c11897d1a
+require (
+	github.com/andybalholm/brotli v1.2.6
+	github.com/gomarkdown/markdown v0.0.0-20250810172220-2e2c11897d1a
+	modernc.org/sqlite v1.40.1
+)
 
 require (
-	github.com/k0kubun/pp/v3 v3.5.0 // indirect
-	github.com/mattn/go-colorable v0.1.14 // indirect
+	github.com/dustin/go-humanize v1.0.1 // indirect
+	github.com/google/uuid v1.6.0 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
-	golang.org/x/sys v0.29.0 // indirect
-	golang.org/x/text v0.26.0 // indirect
+	github.com/ncruces/go-strftime v0.1.9 // indirect
+	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
+	golang.org/x/exp v0.0.0-20250620022241-b7579e27df2b // indirect
+	golang.org/x/sys v0.36.0 // indirect
+	modernc.org/libc v1.66.10 // indirect
+	modernc.org/mathutil v1.7.1 // indirect
+	modernc.org/memory v1.11.0 // indirect
 )
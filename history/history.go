@@ -0,0 +1,178 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// DateFormat is the layout of Snapshot.Date
+const DateFormat = "2006-01-02"
+
+// Snapshot is the aggregate activity of one repository on one day (UTC)
+type Snapshot struct {
+	Date               string `json:"date"`
+	OpenIssues         int    `json:"open_issues"`
+	OpenPRs            int    `json:"open_prs"`
+	MergedPRs          int    `json:"merged_prs"`
+	NewIssues          int    `json:"new_issues"`
+	ActiveContributors int    `json:"active_contributors"`
+}
+
+// Series is one named aggregate over consecutive snapshots
+type Series struct {
+	Name   string
+	Dates  []string
+	Values []int
+}
+
+// Path returns the append log for a repository under dir
+func Path(dir, owner, repo string) string {
+	return filepath.Join(dir, owner, repo+".jsonl")
+}
+
+// Append adds snapshots to the end of the log for a repository
+func Append(dir, owner, repo string, snapshots ...Snapshot) error {
+	name := Path(dir, owner, repo)
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	for _, s := range snapshots {
+		if err := enc.Encode(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Load reads the log for a repository.
+// When a date was recorded more than once, the last record wins.
+// The result has one snapshot per date, oldest first.
+func Load(dir, owner, repo string) ([]Snapshot, error) {
+	f, err := os.Open(Path(dir, owner, repo))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	byDate := map[string]Snapshot{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var s Snapshot
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			return nil, err
+		}
+		byDate[s.Date] = s
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	snapshots := make([]Snapshot, 0, len(byDate))
+	for _, s := range byDate {
+		snapshots = append(snapshots, s)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Date < snapshots[j].Date
+	})
+	return snapshots, nil
+}
+
+// SeriesOf splits snapshots into one series per aggregate
+func SeriesOf(snapshots []Snapshot) []Series {
+	fields := []struct {
+		name  string
+		value func(Snapshot) int
+	}{
+		{"Open issues", func(s Snapshot) int { return s.OpenIssues }},
+		{"Open PRs", func(s Snapshot) int { return s.OpenPRs }},
+		{"Merged PRs", func(s Snapshot) int { return s.MergedPRs }},
+		{"New issues", func(s Snapshot) int { return s.NewIssues }},
+		{"Active contributors", func(s Snapshot) int { return s.ActiveContributors }},
+	}
+
+	series := []Series{}
+	for _, field := range fields {
+		s := Series{Name: field.name}
+		for _, snapshot := range snapshots {
+			s.Dates = append(s.Dates, snapshot.Date)
+			s.Values = append(s.Values, field.value(snapshot))
+		}
+		series = append(series, s)
+	}
+	return series
+}
+
+// Tally accumulates activity for a fixed set of days
+type Tally struct {
+	days         []string
+	snapshots    map[string]*Snapshot
+	contributors map[string]map[string]bool
+}
+
+// NewTally creates a tally for the UTC dates of days
+func NewTally(days ...time.Time) *Tally {
+	t := &Tally{
+		snapshots:    map[string]*Snapshot{},
+		contributors: map[string]map[string]bool{},
+	}
+	for _, day := range days {
+		date := day.UTC().Format(DateFormat)
+		t.days = append(t.days, date)
+		t.snapshots[date] = &Snapshot{Date: date}
+		t.contributors[date] = map[string]bool{}
+	}
+	return t
+}
+
+// NewIssue counts an issue created at when
+func (t *Tally) NewIssue(when time.Time) {
+	if s, ok := t.snapshots[when.UTC().Format(DateFormat)]; ok {
+		s.NewIssues++
+	}
+}
+
+// MergedPR counts a pull request merged at when
+func (t *Tally) MergedPR(when time.Time) {
+	if s, ok := t.snapshots[when.UTC().Format(DateFormat)]; ok {
+		s.MergedPRs++
+	}
+}
+
+// Contributor records that login was active at when
+func (t *Tally) Contributor(when time.Time, login string) {
+	if login == "" {
+		return
+	}
+	if c, ok := t.contributors[when.UTC().Format(DateFormat)]; ok {
+		c[login] = true
+	}
+}
+
+// Snapshots returns one snapshot per tallied day, in the order the days were given
+func (t *Tally) Snapshots() []Snapshot {
+	snapshots := []Snapshot{}
+	for _, date := range t.days {
+		s := *t.snapshots[date]
+		s.ActiveContributors = len(t.contributors[date])
+		snapshots = append(snapshots, s)
+	}
+	return snapshots
+}
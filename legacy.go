@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+)
+
+// legacyMain runs the boolean flags that predate the subcommands, e.g. --fetch --render --serve,
+// and returns the exit code
+//
+// Deprecated: use the subcommands.
+func legacyMain(args []string) int {
+	config := defaultConfig()
+	var webhooks webhookFlags
+
+	fs := flag.NewFlagSet("kokkos-dashboard", flag.ContinueOnError)
+	fetchFlag := fs.Bool("fetch", false, "Deprecated: use the fetch command")
+	renderFlag := fs.Bool("render", false, "Deprecated: use the render command")
+	serveFlag := fs.Bool("serve", false, "Deprecated: use the serve command")
+	digestFlag := fs.Bool("digest", false, "Deprecated: use the digest command")
+	storeFlags(fs, &config)
+	siteFlags(fs, &config)
+	notifyFlags(fs, &config, &webhooks)
+	serveFlags(fs, &config)
+	digestFlags(fs, &config)
+	githubFlags(fs, &config)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(fs.Output(), "unexpected argument %q\n", fs.Arg(0))
+		return exitUsage
+	}
+	if err := config.check(); err != nil {
+		fmt.Fprintln(fs.Output(), err)
+		return exitUsage
+	}
+
+	log.Println("warning: --fetch, --render, --serve, --dev and --digest are deprecated; use the fetch, render, serve and digest commands")
+
+	if err := envWebhooks(&webhooks); err != nil {
+		log.Println(err)
+		return exitUsage
+	}
+	config.Webhooks = webhooks
+	config.Since = sinceWorkdays(config.Clock.Now(), config.Workdays)
+
+	stopReplay, err := startReplay(&config)
+	if err != nil {
+		log.Println(err)
+		return exitError
+	}
+	defer stopReplay()
+	if err := setupGitHubAuth(&config); err != nil {
+		log.Println(err)
+		return exitError
+	}
+
+	steps := []struct {
+		enabled bool
+		name    string
+		run     func(Config) error
+	}{
+		{*fetchFlag, "fetch", fetch},
+		{*renderFlag, "render", renderAndNotify},
+		{*digestFlag, "digest", digest},
+		{*serveFlag || config.Dev, "serve", serveOrDev},
+	}
+	for _, step := range steps {
+		if !step.enabled {
+			continue
+		}
+		if err := step.run(config); err != nil {
+			log.Printf("%s error: %v", step.name, err)
+			return exitError
+		}
+	}
+	return exitOK
+}
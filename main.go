@@ -1,53 +1,92 @@
 package main
 
 import (
-	"flag"
 	"fmt"
 	"log"
-	"net/http"
+	"net/url"
 	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
+
+	"kokkos-dashboard/fixture"
+	"kokkos-dashboard/github"
+	"kokkos-dashboard/notify"
+	"kokkos-dashboard/store"
 )
 
 type Config struct {
-	GitHubToken  string
-	Repositories []struct {
+	GitHubToken      string      // whitespace-separated personal tokens
+	GitHubApp        string      // ID or client ID of a GitHub App to authenticate as instead
+	GitHubAppInstall string      // the installation of the app
+	GitHubAppKeyFile string      // the app's PEM private key
+	GitHubAuth       github.Auth // built from the above when a command starts; nil for unauthenticated requests
+	GitHubBaseURL    string      // REST API root, https://HOST/api/v3 for GitHub Enterprise Server
+	GitHubGraphQLURL string      // if not next to GitHubBaseURL
+	GitHubAPIVersion string
+	GitHubBackend    string // "rest" or "graphql"
+	GitHubRecordDir  string // save GitHub responses here as fixtures
+	GitHubReplayDir  string // answer GitHub requests from the fixtures here instead
+	WebhookSecret    string // enables the GitHub webhook receiver in serve mode
+	Repositories     []struct {
 		Owner string
 		Name  string
 	}
-	FetchDir  string
-	OutputDir string
-	SiteRoot  string
-	Since     time.Time
+	Branches   []string // names or path.Match patterns of the branches whose commits fetch lists
+	Store      string   // "fs" or "sqlite"
+	FetchDir   string   // root of the "fs" store
+	SQLitePath string   // database of the "sqlite" store
+	HistoryDir string
+	OutputDir  string
+	SiteRoot   string
+	Workdays   int       // how far back fetch and render look
+	Since      time.Time // the start of that window, set when a command starts
+	Clock      Clock
+
+	ListenAddr      string
+	TLSCert         string
+	TLSKey          string
+	RefreshInterval time.Duration // of serve; 0 disables
+	Dev             bool          // serve re-renders when the theme changes
+	DevWatchData    bool          // and also when the store changes
+
+	ThemeDir          string // templates/ and static/ here override the built-in ones
+	DigestTemplateDir string
+
+	Webhooks     []notify.Target
+	NotifyDryRun bool
+	SiteURL      string // where the site is published, which notifications link to
 }
 
-func main() {
-	// Define command-line flags
-	fetchFlag := flag.Bool("fetch", false, "Fetch GitHub activity data")
-	renderFlag := flag.Bool("render", false, "Render static site from fetched data")
-	serveFlag := flag.Bool("serve", false, "Serve render output dir")
-	siteRootFlag := flag.String("site-root", "/", "Site root for render")
-	flag.Parse()
-
-	// account for the weekend when fetching two days back
-	now := time.Now()
-	workdaysFound := 0
-	daysBack := 0
+// webhookFlags collects repeated --notify values
+type webhookFlags []notify.Target
 
-	for workdaysFound < 2 {
-		daysBack++
-		checkDate := now.AddDate(0, 0, -daysBack)
-		weekday := checkDate.Weekday()
+func (w *webhookFlags) String() string {
+	return fmt.Sprint(*w)
+}
 
-		// Skip Saturday (6) and Sunday (0)
-		if weekday != time.Saturday && weekday != time.Sunday {
-			workdaysFound++
-		}
+func (w *webhookFlags) Set(value string) error {
+	target, err := notify.ParseTarget(value)
+	if err != nil {
+		return err
 	}
+	*w = append(*w, target)
+	return nil
+}
 
-	// Load configuration
-	config := Config{
-		GitHubToken: os.Getenv("KOKKOS_DASHBOARD_TOKEN"),
+// defaultConfig is the configuration before command-line flags are applied
+func defaultConfig() Config {
+	return Config{
+		GitHubToken:      os.Getenv("KOKKOS_DASHBOARD_TOKEN"),
+		GitHubApp:        os.Getenv("KOKKOS_DASHBOARD_APP_ID"),
+		GitHubAppInstall: os.Getenv("KOKKOS_DASHBOARD_APP_INSTALLATION_ID"),
+		GitHubAppKeyFile: os.Getenv("KOKKOS_DASHBOARD_APP_KEY_FILE"),
+		GitHubBaseURL:    github.DefaultBaseURL,
+		GitHubAPIVersion: github.DefaultAPIVersion,
+		GitHubBackend:    "rest",
+		WebhookSecret:    os.Getenv("KOKKOS_DASHBOARD_WEBHOOK_SECRET"),
 		Repositories: []struct {
 			Owner string
 			Name  string
@@ -65,32 +104,174 @@ func main() {
 			{Owner: "kokkos", Name: "mdspan"},
 			{Owner: "kokkos", Name: "kokkos-tutorials"},
 		},
-		FetchDir:  "data/",
-		OutputDir: "public/",
-		SiteRoot:  *siteRootFlag,
-		Since:     now.AddDate(0, 0, -daysBack),
+		Branches:   []string{"develop", "main", "master", "release*"},
+		Store:      "fs",
+		FetchDir:   "data/",
+		SQLitePath: "data.sqlite",
+		HistoryDir: "history/",
+		OutputDir:  "public/",
+		SiteRoot:   "/",
+		Workdays:   2,
+		Clock:      systemClock{},
+
+		ListenAddr: ":8080",
+	}
+}
+
+// check reports settings that can't work
+func (config Config) check() error {
+	if config.Workdays < 1 {
+		return fmt.Errorf("--workdays must be at least 1")
+	}
+	if config.GitHubBackend != "rest" && config.GitHubBackend != "graphql" {
+		return fmt.Errorf("unknown GitHub backend %q", config.GitHubBackend)
+	}
+	for _, pattern := range config.Branches {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("bad branch pattern %q: %w", pattern, err)
+		}
+	}
+	if config.SiteURL != "" {
+		if u, err := url.Parse(config.SiteURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("--site-url %q is not an http or https URL", config.SiteURL)
+		}
+	}
+	app := []string{config.GitHubApp, config.GitHubAppInstall, config.GitHubAppKeyFile}
+	if slices.Contains(app, "") && slices.ContainsFunc(app, func(s string) bool { return s != "" }) {
+		return fmt.Errorf("GitHub App authentication needs --github-app, --github-app-installation and --github-app-key")
+	}
+	return nil
+}
+
+// envWebhooks appends the webhooks in KOKKOS_DASHBOARD_WEBHOOKS.
+// Webhook URLs are secrets, so they can also come from the environment.
+func envWebhooks(webhooks *webhookFlags) error {
+	for _, value := range strings.Fields(os.Getenv("KOKKOS_DASHBOARD_WEBHOOKS")) {
+		if err := webhooks.Set(value); err != nil {
+			return fmt.Errorf("KOKKOS_DASHBOARD_WEBHOOKS: %w", err)
+		}
+	}
+	return nil
+}
+
+// startReplay points config at a local server answering from config.GitHubReplayDir, if set.
+// The returned function stops the server.
+func startReplay(config *Config) (func(), error) {
+	if config.GitHubReplayDir == "" {
+		return func() {}, nil
+	}
+	if config.GitHubRecordDir != "" {
+		return nil, fmt.Errorf("--github-record and --github-replay can't be used together")
+	}
+	server, err := fixture.NewServer(config.GitHubReplayDir)
+	if err != nil {
+		return nil, err
+	}
+	config.GitHubBaseURL = server.URL
+	return server.Close, nil
+}
+
+// setupGitHubAuth sets config.GitHubAuth to the configured credentials: each token in
+// config.GitHubToken and the GitHub App, pooled if there are several.
+// Replayed fixtures need no credentials.
+func setupGitHubAuth(config *Config) error {
+	if config.GitHubReplayDir != "" {
+		return nil
 	}
 
-	// fetch
-	if *fetchFlag {
-		fmt.Println("Fetching GitHub activity...")
-		if err := fetch(config); err != nil {
-			log.Fatalf("fetch error: %v", err)
+	pool := github.NewPool()
+	var auth github.Auth
+	n := 0
+	for _, token := range strings.Fields(config.GitHubToken) {
+		auth = github.TokenAuth(token)
+		pool.Add("token …"+token[max(0, len(token)-4):], auth)
+		n++
+	}
+	if config.GitHubApp != "" {
+		installation, err := strconv.ParseInt(config.GitHubAppInstall, 10, 64)
+		if err != nil {
+			return fmt.Errorf("GitHub App installation %q: %w", config.GitHubAppInstall, err)
+		}
+		key, err := os.ReadFile(config.GitHubAppKeyFile)
+		if err != nil {
+			return err
 		}
+		auth, err = github.NewAppAuth(config.GitHubApp, installation, key, github.Options{
+			BaseURL:    config.GitHubBaseURL,
+			APIVersion: config.GitHubAPIVersion,
+		})
+		if err != nil {
+			return err
+		}
+		log.Printf("authenticating as installation %d of GitHub App %s", installation, config.GitHubApp)
+		pool.Add("app "+config.GitHubApp, auth)
+		n++
 	}
 
-	// render
-	if *renderFlag {
-		fmt.Println("Rendering static site...")
-		if err := render(config); err != nil {
-			log.Fatalf("render error: %v", err)
+	if n > 1 {
+		log.Printf("spreading GitHub requests over %d credentials", n)
+		auth = pool
+	}
+	config.GitHubAuth = auth
+	return nil
+}
+
+// credentialUsage notes the requests each pooled credential has made so far.
+// The returned function logs how many they made since and how many they have left.
+func credentialUsage(config Config) func() {
+	pool, ok := config.GitHubAuth.(*github.Pool)
+	if !ok {
+		return func() {}
+	}
+	before := pool.Usage()
+	return func() {
+		for i, u := range pool.Usage() {
+			requests := u.Requests - before[i].Requests
+			if u.Remaining < 0 {
+				log.Printf("%s: %d requests", u.Name, requests)
+				continue
+			}
+			log.Printf("%s: %d requests, %d of %d left until %s",
+				u.Name, requests, u.Remaining, u.Limit, u.Reset.Format(time.TimeOnly))
 		}
 	}
+}
+
+func main() {
+	args := os.Args[1:]
+	if len(args) > 0 && strings.HasPrefix(args[0], "-") && !isHelp(args[0]) {
+		os.Exit(legacyMain(args))
+	}
+	os.Exit(runCommand(args))
+}
+
+// sinceWorkdays goes back from now until n workdays have been covered, accounting for the weekend
+func sinceWorkdays(now time.Time, n int) time.Time {
+	workdaysFound := 0
+	daysBack := 0
+
+	for workdaysFound < n {
+		daysBack++
+		checkDate := now.AddDate(0, 0, -daysBack)
+		weekday := checkDate.Weekday()
+
+		// Skip Saturday (6) and Sunday (0)
+		if weekday != time.Saturday && weekday != time.Sunday {
+			workdaysFound++
+		}
+	}
+	return now.AddDate(0, 0, -daysBack)
+}
+
+// gitHubWebURL is the web interface next to config.GitHubBaseURL, which rendered pages link to
+func (config Config) gitHubWebURL() string {
+	return github.Options{BaseURL: config.GitHubBaseURL}.WebURL()
+}
 
-	if *serveFlag {
-		fs := http.FileServer(http.Dir(config.OutputDir))
-		http.Handle("/", fs)
-		log.Println("serve on :8080")
-		http.ListenAndServe(":8080", nil)
+// openStore opens the store that connects fetch to render
+func openStore(config Config) (store.Store, error) {
+	if config.Store == "sqlite" {
+		return store.Open(config.Store, config.SQLitePath)
 	}
+	return store.Open(config.Store, config.FetchDir)
 }
@@ -0,0 +1,135 @@
+// Package metrics keeps counters and gauges and writes them in the Prometheus text format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Registry is a set of metrics that are exposed together
+type Registry struct {
+	mu      sync.Mutex
+	metrics []*metric
+}
+
+// Default is where NewCounter and NewGauge register
+var Default = &Registry{}
+
+type metric struct {
+	name   string
+	help   string
+	kind   string // "counter" or "gauge"
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]float64 // keyed by the joined label values
+}
+
+// Counter is a value that only goes up
+type Counter struct{ m *metric }
+
+// Gauge is a value that is set
+type Gauge struct{ m *metric }
+
+func (r *Registry) register(name, help, kind string, labels []string) *metric {
+	m := &metric{name: name, help: help, kind: kind, labels: labels, values: map[string]float64{}}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, m)
+	return m
+}
+
+// NewCounter registers a counter in Default
+func NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{Default.register(name, help, "counter", labels)}
+}
+
+// NewGauge registers a gauge in Default
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{Default.register(name, help, "gauge", labels)}
+}
+
+// key joins label values; there must be one per label name
+func (m *metric) key(labelValues []string) string {
+	if len(labelValues) != len(m.labels) {
+		panic(fmt.Sprintf("metric %s has %d labels, got %d values", m.name, len(m.labels), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff")
+}
+
+// Inc adds one
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v, which must not be negative
+func (c *Counter) Add(v float64, labelValues ...string) {
+	key := c.m.key(labelValues)
+	c.m.mu.Lock()
+	c.m.values[key] += v
+	c.m.mu.Unlock()
+}
+
+// Set replaces the value
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	key := g.m.key(labelValues)
+	g.m.mu.Lock()
+	g.m.values[key] = v
+	g.m.mu.Unlock()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// WriteText writes every metric in the Prometheus text exposition format
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	metrics := append([]*metric{}, r.metrics...)
+	r.mu.Unlock()
+
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].name < metrics[j].name })
+
+	for _, m := range metrics {
+		m.mu.Lock()
+		keys := make([]string, 0, len(m.values))
+		for key := range m.values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind); err != nil {
+			m.mu.Unlock()
+			return err
+		}
+		for _, key := range keys {
+			labels := ""
+			if len(m.labels) > 0 {
+				pairs := []string{}
+				for i, value := range strings.Split(key, "\xff") {
+					pairs = append(pairs, fmt.Sprintf(`%s="%s"`, m.labels[i], labelEscaper.Replace(value)))
+				}
+				labels = "{" + strings.Join(pairs, ",") + "}"
+			}
+			if _, err := fmt.Fprintf(w, "%s%s %g\n", m.name, labels, m.values[key]); err != nil {
+				m.mu.Unlock()
+				return err
+			}
+		}
+		m.mu.Unlock()
+	}
+	return nil
+}
+
+// Handler serves the registry at a /metrics endpoint
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := r.WriteText(w); err != nil {
+			log.Printf("metrics write error: %v", err)
+		}
+	})
+}
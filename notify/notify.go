@@ -0,0 +1,289 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"kokkos-dashboard/ratelimit"
+)
+
+// Format is the payload an incoming webhook expects
+type Format string
+
+const (
+	Slack   Format = "slack"   // Slack blocks
+	Matrix  Format = "matrix"  // matrix-hookshot generic webhook
+	Discord Format = "discord" // Discord webhook
+)
+
+// Discord rejects messages with longer content
+const discordMaxContent = 2000
+
+// Slack rejects blocks with longer text
+const (
+	slackMaxHeader  = 150
+	slackMaxSection = 3000
+)
+
+// Target is a webhook URL and the format it accepts
+type Target struct {
+	Format Format
+	URL    string
+}
+
+// Redacted is the target without the secret part of its URL, for logs
+func (t Target) Redacted() string {
+	u, err := url.Parse(t.URL)
+	if err != nil || u.Host == "" {
+		return string(t.Format)
+	}
+	return fmt.Sprintf("%s %s://%s/…", t.Format, u.Scheme, u.Host)
+}
+
+// Check reports a URL that can't be posted to, without repeating the secret URL
+func (t Target) Check() error {
+	u, err := url.Parse(t.URL)
+	if err != nil {
+		return fmt.Errorf("webhook URL doesn't parse")
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return fmt.Errorf("webhook URL scheme is %q, not https or http", u.Scheme)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("webhook URL has no host")
+	}
+	return nil
+}
+
+// ParseTarget parses "format=url", e.g. "slack=https://hooks.slack.com/services/..."
+func ParseTarget(s string) (Target, error) {
+	format, url, ok := strings.Cut(s, "=")
+	if !ok || url == "" {
+		return Target{}, fmt.Errorf("webhook %q is not format=url", s)
+	}
+	t := Target{Format: Format(strings.ToLower(format)), URL: url}
+	switch t.Format {
+	case Slack, Matrix, Discord:
+		return t, nil
+	}
+	return Target{}, fmt.Errorf("unknown webhook format %q", format)
+}
+
+// Item is one line of a summary
+type Item struct {
+	Text string
+	URL  string
+}
+
+// Section groups items under a heading
+type Section struct {
+	Heading string
+	Items   []Item
+}
+
+// Summary is the message posted to every target
+type Summary struct {
+	Title    string
+	URL      string // optional link to the full dashboard
+	Sections []Section
+}
+
+// Notifier posts summaries to webhooks
+type Notifier struct {
+	client *ratelimit.Client
+	dryRun bool
+	out    io.Writer
+}
+
+// New creates a notifier that sends through client.
+// With dryRun, payloads are written to out instead of being posted.
+func New(client *ratelimit.Client, dryRun bool, out io.Writer) *Notifier {
+	return &Notifier{client: client, dryRun: dryRun, out: out}
+}
+
+// Send posts summary to each target, stopping at the first failure
+func (n *Notifier) Send(targets []Target, summary Summary) error {
+	for _, target := range targets {
+		payload, err := Payload(target.Format, summary)
+		if err != nil {
+			return err
+		}
+
+		if n.dryRun {
+			fmt.Fprintf(n.out, "%s\n%s\n", target.Redacted(), payload)
+			continue
+		}
+
+		req, err := http.NewRequest("POST", target.URL, bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		log.Printf("notify %s webhook (%d bytes)", target.Format, len(payload))
+		resp, err := n.client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("%s webhook error: %s", target.Format, resp.Status)
+		}
+	}
+	return nil
+}
+
+// Payload encodes summary the way format expects
+func Payload(format Format, summary Summary) ([]byte, error) {
+	switch format {
+	case Slack:
+		return json.Marshal(slackPayload(summary))
+	case Matrix:
+		return json.Marshal(matrixPayload(summary))
+	case Discord:
+		return json.Marshal(discordPayload(summary))
+	}
+	return nil, fmt.Errorf("unknown webhook format %q", format)
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type slackBlock struct {
+	Type string     `json:"type"`
+	Text *slackText `json:"text,omitempty"`
+}
+
+// slackEscape escapes the characters Slack mrkdwn treats as control sequences
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// truncate shortens s to at most n characters, ending it with an ellipsis if it was longer
+func truncate(s string, n int) string {
+	if len([]rune(s)) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
+
+// fitLines joins lines with newlines into at most n characters. If they don't fit, it keeps the lines
+// that do and ends with a line holding an ellipsis, so no line, and so no link, is cut in half.
+func fitLines(lines []string, n int) string {
+	joined := strings.Join(lines, "\n")
+	if utf8.RuneCountInString(joined) <= n {
+		return joined
+	}
+	const more = "\n…"
+	var kept []string
+	length := utf8.RuneCountInString(more)
+	for i, line := range lines {
+		length += utf8.RuneCountInString(line)
+		if i > 0 {
+			length++ // newline
+		}
+		if length > n {
+			break
+		}
+		kept = append(kept, line)
+	}
+	if len(kept) == 0 {
+		return truncate(lines[0], n)
+	}
+	return strings.Join(kept, "\n") + more
+}
+
+func slackPayload(summary Summary) any {
+	blocks := []slackBlock{
+		{Type: "header", Text: &slackText{Type: "plain_text", Text: truncate(summary.Title, slackMaxHeader)}},
+	}
+	for _, section := range summary.Sections {
+		lines := []string{fmt.Sprintf("*%s*", slackEscape(section.Heading))}
+		for _, item := range section.Items {
+			if item.URL != "" {
+				lines = append(lines, fmt.Sprintf("• <%s|%s>", item.URL, slackEscape(item.Text)))
+			} else {
+				lines = append(lines, fmt.Sprintf("• %s", slackEscape(item.Text)))
+			}
+		}
+		blocks = append(blocks, slackBlock{Type: "section", Text: &slackText{Type: "mrkdwn", Text: fitLines(lines, slackMaxSection)}})
+	}
+	if summary.URL != "" {
+		blocks = append(blocks, slackBlock{Type: "section", Text: &slackText{Type: "mrkdwn", Text: fmt.Sprintf("<%s|Open the dashboard>", summary.URL)}})
+	}
+
+	return struct {
+		Text   string       `json:"text"` // notification fallback
+		Blocks []slackBlock `json:"blocks"`
+	}{summary.Title, blocks}
+}
+
+func matrixPayload(summary Summary) any {
+	var text, h strings.Builder
+	text.WriteString(summary.Title)
+	fmt.Fprintf(&h, "<h3>%s</h3>", html.EscapeString(summary.Title))
+	for _, section := range summary.Sections {
+		fmt.Fprintf(&text, "\n\n%s", section.Heading)
+		fmt.Fprintf(&h, "<h4>%s</h4><ul>", html.EscapeString(section.Heading))
+		for _, item := range section.Items {
+			fmt.Fprintf(&text, "\n- %s", item.Text)
+			if item.URL != "" {
+				fmt.Fprintf(&text, " %s", item.URL)
+				fmt.Fprintf(&h, `<li><a href="%s">%s</a></li>`, html.EscapeString(item.URL), html.EscapeString(item.Text))
+			} else {
+				fmt.Fprintf(&h, "<li>%s</li>", html.EscapeString(item.Text))
+			}
+		}
+		h.WriteString("</ul>")
+	}
+	if summary.URL != "" {
+		fmt.Fprintf(&text, "\n\n%s", summary.URL)
+		fmt.Fprintf(&h, `<p><a href="%s">Open the dashboard</a></p>`, html.EscapeString(summary.URL))
+	}
+
+	return struct {
+		Text string `json:"text"`
+		HTML string `json:"html"`
+	}{text.String(), h.String()}
+}
+
+// discordEscape escapes the characters Discord markdown treats as formatting
+var discordEscape = strings.NewReplacer(
+	`\`, `\\`, "*", `\*`, "_", `\_`, "~", `\~`, "`", "\\`", "|", `\|`,
+	"[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`, "#", `\#`,
+).Replace
+
+func discordPayload(summary Summary) any {
+	lines := []string{fmt.Sprintf("**%s**", discordEscape(summary.Title))}
+	for _, section := range summary.Sections {
+		lines = append(lines, "", fmt.Sprintf("__%s__", discordEscape(section.Heading)))
+		for _, item := range section.Items {
+			if item.URL != "" {
+				// angle brackets suppress link previews
+				lines = append(lines, fmt.Sprintf("- [%s](<%s>)", discordEscape(item.Text), item.URL))
+			} else {
+				lines = append(lines, fmt.Sprintf("- %s", discordEscape(item.Text)))
+			}
+		}
+	}
+	// the dashboard link is kept however much else is left out
+	var link string
+	if summary.URL != "" {
+		link = "\n\n" + summary.URL
+	}
+
+	return struct {
+		Content string `json:"content"`
+	}{fitLines(lines, discordMaxContent-utf8.RuneCountInString(link)) + link}
+}
@@ -0,0 +1,218 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"kokkos-dashboard/ratelimit"
+)
+
+var testSummary = Summary{
+	Title: "Kokkos activity",
+	URL:   "https://kokkos.github.io/kokkos-dashboard/",
+	Sections: []Section{{
+		Heading: "kokkos/kokkos",
+		Items: []Item{
+			{Text: "#101 Use <T> & *fast* paths for [SIMD]_reductions", URL: "https://github.com/kokkos/kokkos/pull/101"},
+			{Text: "2 new issues"},
+		},
+	}},
+}
+
+// post sends summary to a stand-in webhook in format and decodes what it received into payload
+func post(t *testing.T, format Format, summary Summary, payload any) {
+	t.Helper()
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("%s %s with Content-Type %q", r.Method, r.URL, r.Header.Get("Content-Type"))
+		}
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	notifier := New(ratelimit.NewRateLimitedClient(0), false, io.Discard)
+	if err := notifier.Send([]Target{{Format: format, URL: server.URL + "/hook/secret"}}, summary); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(body, payload); err != nil {
+		t.Fatalf("payload %s: %v", body, err)
+	}
+}
+
+type slackTestPayload struct {
+	Text   string `json:"text"`
+	Blocks []struct {
+		Type string `json:"type"`
+		Text struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"text"`
+	} `json:"blocks"`
+}
+
+func TestSlack(t *testing.T) {
+	var payload slackTestPayload
+	post(t, Slack, testSummary, &payload)
+
+	if len(payload.Blocks) != 3 {
+		t.Fatalf("%d blocks, want a header, a section and the dashboard link", len(payload.Blocks))
+	}
+	if header := payload.Blocks[0]; header.Type != "header" || header.Text.Type != "plain_text" || header.Text.Text != "Kokkos activity" {
+		t.Errorf("header %+v", header)
+	}
+	want := "*kokkos/kokkos*\n" +
+		"• <https://github.com/kokkos/kokkos/pull/101|#101 Use &lt;T&gt; &amp; *fast* paths for [SIMD]_reductions>\n" +
+		"• 2 new issues"
+	if section := payload.Blocks[1]; section.Type != "section" || section.Text.Type != "mrkdwn" || section.Text.Text != want {
+		t.Errorf("section %q, want %q", section.Text.Text, want)
+	}
+	if link := payload.Blocks[2].Text.Text; link != "<https://kokkos.github.io/kokkos-dashboard/|Open the dashboard>" {
+		t.Errorf("dashboard link %q", link)
+	}
+}
+
+// longSection has more linked items than fit in a message
+func longSection() Section {
+	section := Section{Heading: "kokkos/kokkos"}
+	for i := range 100 {
+		section.Items = append(section.Items, Item{Text: fmt.Sprintf("#%d %s", i, strings.Repeat("x", 50)), URL: fmt.Sprintf("https://github.com/kokkos/kokkos/issues/%d", i)})
+	}
+	return section
+}
+
+// checkLines checks that text has at most n characters, ends in an ellipsis line, and that its other lines are whole
+func checkLines(t *testing.T, text string, n int, whole *regexp.Regexp) {
+	t.Helper()
+	if got := utf8.RuneCountInString(text); got > n {
+		t.Errorf("%d characters, want at most %d", got, n)
+	}
+	lines := strings.Split(text, "\n")
+	if lines[len(lines)-1] != "…" {
+		t.Errorf("last line %q, want an ellipsis", lines[len(lines)-1])
+	}
+	for _, line := range lines[:len(lines)-1] {
+		if !whole.MatchString(line) {
+			t.Errorf("line %q was cut", line)
+		}
+	}
+}
+
+func TestSlackLimits(t *testing.T) {
+	summary := Summary{Title: strings.Repeat("t", 200), Sections: []Section{longSection()}}
+
+	var payload slackTestPayload
+	post(t, Slack, summary, &payload)
+	if n := utf8.RuneCountInString(payload.Blocks[0].Text.Text); n != slackMaxHeader {
+		t.Errorf("header of %d characters, want %d", n, slackMaxHeader)
+	}
+	checkLines(t, payload.Blocks[1].Text.Text, slackMaxSection,
+		regexp.MustCompile(`^(\*kokkos/kokkos\*|• <https://github.com/kokkos/kokkos/issues/\d+\|#\d+ x{50}>)$`))
+}
+
+func TestMatrix(t *testing.T) {
+	var payload struct {
+		Text string `json:"text"`
+		HTML string `json:"html"`
+	}
+	post(t, Matrix, testSummary, &payload)
+
+	wantText := "Kokkos activity\n\nkokkos/kokkos\n" +
+		"- #101 Use <T> & *fast* paths for [SIMD]_reductions https://github.com/kokkos/kokkos/pull/101\n" +
+		"- 2 new issues\n\nhttps://kokkos.github.io/kokkos-dashboard/"
+	if payload.Text != wantText {
+		t.Errorf("text %q, want %q", payload.Text, wantText)
+	}
+	wantHTML := "<h3>Kokkos activity</h3><h4>kokkos/kokkos</h4><ul>" +
+		`<li><a href="https://github.com/kokkos/kokkos/pull/101">#101 Use &lt;T&gt; &amp; *fast* paths for [SIMD]_reductions</a></li>` +
+		"<li>2 new issues</li></ul>" +
+		`<p><a href="https://kokkos.github.io/kokkos-dashboard/">Open the dashboard</a></p>`
+	if payload.HTML != wantHTML {
+		t.Errorf("html %q, want %q", payload.HTML, wantHTML)
+	}
+}
+
+func TestDiscord(t *testing.T) {
+	var payload struct {
+		Content string `json:"content"`
+	}
+	post(t, Discord, testSummary, &payload)
+
+	want := "**Kokkos activity**\n\n__kokkos/kokkos__\n" +
+		`- [\#101 Use \<T\> & \*fast\* paths for \[SIMD\]\_reductions](<https://github.com/kokkos/kokkos/pull/101>)` + "\n" +
+		"- 2 new issues\n\nhttps://kokkos.github.io/kokkos-dashboard/"
+	if payload.Content != want {
+		t.Errorf("content %q, want %q", payload.Content, want)
+	}
+}
+
+func TestDiscordLimit(t *testing.T) {
+	var payload struct {
+		Content string `json:"content"`
+	}
+	summary := Summary{Title: "Kokkos activity", URL: "https://kokkos.github.io/kokkos-dashboard/", Sections: []Section{longSection()}}
+	post(t, Discord, summary, &payload)
+
+	content, ok := strings.CutSuffix(payload.Content, "\n\n"+summary.URL)
+	if !ok {
+		t.Errorf("content doesn't end with the dashboard link")
+	}
+	checkLines(t, content, discordMaxContent-len("\n\n"+summary.URL),
+		regexp.MustCompile(`^(\*\*Kokkos activity\*\*|__kokkos/kokkos__|- \[\\#\d+ x{50}\]\(<https://github.com/kokkos/kokkos/issues/\d+>\)|)$`))
+}
+
+func TestWebhookError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid_blocks", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	notifier := New(ratelimit.NewRateLimitedClient(0), false, io.Discard)
+	if err := notifier.Send([]Target{{Format: Slack, URL: server.URL}}, testSummary); err == nil {
+		t.Error("no error for a rejected payload")
+	}
+}
+
+func TestDryRunRedactsURL(t *testing.T) {
+	var out bytes.Buffer
+	target := Target{Format: Slack, URL: "https://hooks.slack.com/services/T000/B000/XXXXSECRET"}
+	if err := New(nil, true, &out).Send([]Target{target}, testSummary); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "XXXXSECRET") || strings.Contains(out.String(), "/services") {
+		t.Errorf("dry run shows the webhook URL:\n%s", out.String())
+	}
+	if first, _, _ := strings.Cut(out.String(), "\n"); first != "slack https://hooks.slack.com/…" {
+		t.Errorf("dry run starts with %q", first)
+	}
+}
+
+func TestTargetCheck(t *testing.T) {
+	for value, ok := range map[string]bool{
+		"slack=https://hooks.slack.com/services/T000/B000/XXXX": true,
+		"matrix=http://localhost:9000/webhook/abc":              true,
+		"discord=discord.com/api/webhooks/1/abc":                false,
+		"slack=ftp://hooks.slack.com/services/T000":             false,
+		"slack=https:///services/T000":                          false,
+		"slack=https://hooks.slack.com/%zz":                     false,
+	} {
+		target, err := ParseTarget(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := target.Check(); (err == nil) != ok {
+			t.Errorf("%s: error %v", value, err)
+		} else if err != nil && strings.Contains(err.Error(), "services") {
+			t.Errorf("%s: error %q repeats the URL", value, err)
+		}
+	}
+}
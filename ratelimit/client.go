@@ -4,10 +4,52 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
+
+	"kokkos-dashboard/metrics"
+)
+
+var (
+	requestsTotal = metrics.NewCounter("kokkos_dashboard_http_requests_total",
+		"HTTP requests made, by host, endpoint and status code", "host", "endpoint", "code")
+	retriesTotal = metrics.NewCounter("kokkos_dashboard_http_retries_total",
+		"HTTP requests that were retried", "host")
+	rateLimitRemaining = metrics.NewGauge("kokkos_dashboard_rate_limit_remaining",
+		"X-RateLimit-Remaining of the last response", "host")
 )
 
+// objectID matches the hex ID of a commit or another git object, in full or abbreviated
+var objectID = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+
+// endpoint is path with numeric segments and object IDs replaced, to keep the number of label values small
+func endpoint(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if _, err := strconv.Atoi(segment); err == nil {
+			segments[i] = "{n}"
+		} else if objectID.MatchString(segment) {
+			segments[i] = "{sha}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// observe records the outcome of one attempt
+func observe(req *http.Request, resp *http.Response, err error) {
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+		if remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining")); err == nil {
+			rateLimitRemaining.Set(float64(remaining), req.URL.Host)
+		}
+	}
+	requestsTotal.Inc(req.URL.Host, endpoint(req.URL.Path), code)
+}
+
 // Client wraps an HTTP client with per-domain rate limiting
 type Client struct {
 	client      *http.Client
@@ -84,26 +126,57 @@ func (c *Client) Do(req *http.Request) (*http.Response, error) {
 		time.Sleep(waitTime)
 	}
 
-	// Execute the request
-	var resp *http.Response
-	var err error
-	for range 3 {
+	// Execute the request, retrying server errors and throttling responses
+	const attempts = 3
+	for attempt := 1; ; attempt++ {
 
 		// Update last request time
 		limiter.lastRequest = time.Now()
 
-		resp, err = c.client.Do(req)
+		resp, err := c.client.Do(req)
+		observe(req, resp, err)
 
-		if resp.StatusCode < 500 {
-			break
+		if !shouldRetry(resp, err) || attempt == attempts {
+			return resp, err
+		}
+		// a body that can't be replayed would be sent empty
+		if req.Body != nil && req.GetBody == nil {
+			return resp, err
+		}
+
+		wait := retryAfter(resp)
+		if resp != nil {
+			resp.Body.Close()
+		}
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
 		}
 
 		// sleep before retry
-		time.Sleep(5 * time.Second)
-		log.Println("retry request...")
+		retriesTotal.Inc(req.URL.Host)
+		log.Println("retry request in", wait, "...")
+		time.Sleep(wait)
+	}
+}
+
+// shouldRetry is true for transport errors, server errors, and 429 Too Many Requests
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
 	}
+	return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+}
 
-	return resp, err
+// retryAfter is how long the server asked us to wait, or a default
+func retryAfter(resp *http.Response) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return 5 * time.Second
 }
 
 // SetMinInterval updates the minimum interval between requests
@@ -0,0 +1,19 @@
+package ratelimit
+
+import "testing"
+
+func TestEndpoint(t *testing.T) {
+	for path, want := range map[string]string{
+		"/repos/kokkos/kokkos/issues":                                                 "/repos/kokkos/kokkos/issues",
+		"/repos/kokkos/kokkos/issues/101/comments":                                    "/repos/kokkos/kokkos/issues/{n}/comments",
+		"/repos/kokkos/kokkos/commits/4f2a9c1e0b7d3a5c8e6f1d2b9a0c7e5f3d1b8a6c/pulls": "/repos/kokkos/kokkos/commits/{sha}/pulls",
+		"/repos/kokkos/kokkos/commits/4f2a9c1/pulls":                                  "/repos/kokkos/kokkos/commits/{sha}/pulls",
+		"/repos/kokkos/kokkos/branches/release-4.6":                                   "/repos/kokkos/kokkos/branches/release-4.6",
+		"/repos/kokkos/kokkos/branches/develop":                                       "/repos/kokkos/kokkos/branches/develop",
+		"/repos/kokkos/kokkos/actions/runs/15/jobs":                                   "/repos/kokkos/kokkos/actions/runs/{n}/jobs",
+	} {
+		if got := endpoint(path); got != want {
+			t.Errorf("endpoint(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
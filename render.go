@@ -1,10 +1,12 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	"html/template"
 	"kokkos-dashboard/github"
+	"kokkos-dashboard/history"
+	"kokkos-dashboard/metrics"
+	"kokkos-dashboard/store"
 	"log"
 	"os"
 	"path/filepath"
@@ -17,11 +19,61 @@ import (
 	"github.com/gomarkdown/markdown/parser"
 )
 
+var (
+	renderDuration = metrics.NewGauge("kokkos_dashboard_render_duration_seconds",
+		"Duration of the last render")
+	renderedItems = metrics.NewGauge("kokkos_dashboard_rendered_items",
+		"Items on the site after the last render, by kind", "kind")
+)
+
 // Helper structures
 type RepoData struct {
-	Owner  string
-	Repo   string
-	Issues []Issue
+	Owner       string
+	Repo        string
+	URL         string           // of the repository on GitHub's web interface
+	Workflows   []Workflow       // that ran on the default branch in the window, failing first
+	CIChanges   []WorkflowChange // failures and recoveries in the window, oldest first
+	Releases    []Release
+	Discussions []Discussion
+	Branches    []BranchActivity // with commits in the window, by name
+	MostUpvoted []github.Issue   // open issues with 👍 reactions, most first
+	Issues      []Issue
+	Trends      []history.Series
+}
+
+// Release is a release published in the window
+type Release struct {
+	github.Release
+
+	Commit string // SHA of the tagged commit, if known
+}
+
+// Discussion is a discussion updated in the window
+type Discussion struct {
+	github.Discussion
+
+	New bool // created in the window, so its body is shown
+}
+
+// Workflow is the health of an Actions workflow on the default branch
+type Workflow struct {
+	Name   string
+	Latest github.WorkflowRun // the most recent completed run, or the most recent run if none completed
+	Health string             // "passing", "failing", "running", or the latest conclusion, like "cancelled"
+}
+
+// WorkflowChange is a run that started or ended a streak of failures
+type WorkflowChange struct {
+	github.WorkflowRun
+
+	Recovered bool // succeeded after failing, rather than failed after passing
+}
+
+// BranchActivity is the commits on a branch in the window, newest first
+type BranchActivity struct {
+	Name    string
+	Commits []github.BranchCommit
+	Direct  int // how many commits no pull request brought
 }
 
 type Issue struct {
@@ -33,15 +85,21 @@ type Issue struct {
 	PR       *github.PullRequest
 	Reviews  []github.PullRequestReview
 
+	Status       string         // "merged", "closed", "draft", or "open"
 	ReviewStates map[string]int // how many reviews in each state
 }
 
 // Helper functions
-func mdToHTML(md []byte) []byte {
+
+// mdToHTML renders Markdown, linking the GitHub references in it with refs
+func mdToHTML(md []byte, refs *references) []byte {
 	// create markdown parser with extensions
 	extensions := parser.CommonExtensions | parser.AutoHeadingIDs | parser.NoEmptyLineBeforeBlock
 	p := parser.NewWithExtensions(extensions)
 	doc := p.Parse(md)
+	if refs != nil {
+		refs.link(doc)
+	}
 
 	// create HTML renderer with extensions
 	htmlFlags := html.CommonFlags | html.HrefTargetBlank
@@ -51,251 +109,628 @@ func mdToHTML(md []byte) []byte {
 	return markdown.Render(doc, renderer)
 }
 
-func loadIssues(path string) ([]github.Issue, error) {
-	data, err := os.ReadFile(path)
+// sparkline draws values as an inline SVG line chart
+func sparkline(values []int) template.HTML {
+	const width, height = 120, 24
+	if len(values) == 0 {
+		return ""
+	}
+
+	maxValue := 1
+	for _, v := range values {
+		maxValue = max(maxValue, v)
+	}
+
+	points := []string{}
+	for i, v := range values {
+		x := float64(width)
+		if len(values) > 1 {
+			x = float64(i) * width / float64(len(values)-1)
+		}
+		y := height - 2 - float64(v)*(height-4)/float64(maxValue)
+		points = append(points, fmt.Sprintf("%.1f,%.1f", x, y))
+	}
+	last := points[len(points)-1]
+	lastX, lastY, _ := strings.Cut(last, ",")
+
+	return template.HTML(fmt.Sprintf(
+		`<svg class="sparkline" width="%d" height="%d" viewBox="-2 0 %d %d" aria-hidden="true">`+
+			`<polyline points="%s" /><circle cx="%s" cy="%s" r="2" /></svg>`,
+		width+4, height, width+4, height, strings.Join(points, " "), lastX, lastY))
+}
+
+func render(config Config) error {
+	start := time.Now()
+	defer func() {
+		renderDuration.Set(time.Since(start).Seconds())
+	}()
+
+	repoData, err := loadRepoData(config)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if err := renderRepoData(repoData, config); err != nil {
+		return err
 	}
-	var issues []github.Issue
-	if err := json.Unmarshal(data, &issues); err != nil {
+
+	issues, pullRequests, releases, discussions, workflows := 0, 0, 0, 0, 0
+	for _, repo := range repoData {
+		workflows += len(repo.Workflows)
+		releases += len(repo.Releases)
+		discussions += len(repo.Discussions)
+		for _, issue := range repo.Issues {
+			if issue.PullRequest != nil {
+				pullRequests++
+			} else {
+				issues++
+			}
+		}
+	}
+	renderedItems.Set(float64(len(repoData)), "repos")
+	renderedItems.Set(float64(issues), "issues")
+	renderedItems.Set(float64(pullRequests), "pull_requests")
+	renderedItems.Set(float64(releases), "releases")
+	renderedItems.Set(float64(discussions), "discussions")
+	renderedItems.Set(float64(workflows), "workflows")
+	return nil
+}
+
+// loadRepoData reads the store and prepares it for rendering, keyed by owner/repo
+func loadRepoData(config Config) (map[string]*RepoData, error) {
+	st, err := openStore(config)
+	if err != nil {
 		return nil, err
 	}
-	return issues, nil
+	defer st.Close()
+
+	return loadRepos(st, config)
 }
 
-func render(config Config) error {
-	// Read all owner directories
-	ownerDirs, err := os.ReadDir(config.FetchDir)
+// loadRepos prepares every repository in st for rendering, keyed by owner/repo
+func loadRepos(st store.Store, config Config) (map[string]*RepoData, error) {
+	repos, err := st.ListRepos()
+	if err != nil {
+		return nil, err
+	}
+
+	pages, err := dashboardPages(st)
 	if err != nil {
-		return fmt.Errorf("failed to read data directory: %w", err)
+		return nil, err
 	}
 
 	// Map to organize data by org/repo
 	repoData := make(map[string]*RepoData)
 
-	for _, ownerDir := range ownerDirs {
-
-		if !ownerDir.IsDir() {
-			continue
+	for _, repo := range repos {
+		data, err := loadRepo(st, config, pages, repo.Owner, repo.Name)
+		if err != nil {
+			return nil, err
 		}
+		repoData[fmt.Sprintf("%s/%s", repo.Owner, repo.Name)] = data
+	}
+	return repoData, nil
+}
 
-		ownerName := ownerDir.Name()
-		ownerPath := filepath.Join(config.FetchDir, ownerName)
+// loadRepo reads one repository from the store; pages are the dashboardPages that references link to
+func loadRepo(st store.Store, config Config, pages map[string]store.Repo, ownerName, repoName string) (*RepoData, error) {
+	log.Printf("process %s/%s", ownerName, repoName)
 
-		log.Println("process", ownerPath)
+	refs := &references{owner: ownerName, repo: repoName, siteRoot: config.SiteRoot, webURL: config.gitHubWebURL(), pages: pages}
+
+	data := &RepoData{
+		Owner:  ownerName,
+		Repo:   repoName,
+		URL:    fmt.Sprintf("%s/%s/%s", config.gitHubWebURL(), ownerName, repoName),
+		Issues: []Issue{},
+	}
+
+	snapshots, err := history.Load(config.HistoryDir, ownerName, repoName)
+	if err != nil {
+		log.Printf("Warning: failed to load history for %s/%s: %v", ownerName, repoName, err)
+	}
+	if len(snapshots) > 0 {
+		data.Trends = history.SeriesOf(snapshots)
+	}
+
+	if data.Workflows, data.CIChanges, err = loadWorkflows(st, ownerName, repoName, config.Since); err != nil {
+		return nil, err
+	}
 
-		repoDirs, err := os.ReadDir(ownerPath)
+	if data.Releases, err = loadReleases(st, refs, ownerName, repoName, config.Since); err != nil {
+		return nil, err
+	}
+
+	if data.Discussions, err = loadDiscussions(st, refs, ownerName, repoName, config.Since); err != nil {
+		return nil, err
+	}
+
+	if data.Branches, err = loadBranches(st, ownerName, repoName, config.Since); err != nil {
+		return nil, err
+	}
+
+	if data.MostUpvoted, err = loadMostUpvoted(st, ownerName, repoName); err != nil {
+		return nil, err
+	}
+
+	issues, err := st.ListIssues(ownerName, repoName)
+	if err != nil {
+		log.Printf("Warning: failed to load issues for %s: %v", ownerName, err)
+	}
+
+	for _, issue := range issues {
+		issueData, err := loadIssue(st, refs, ownerName, repoName, issue, config.Since)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		data.Issues = append(data.Issues, issueData)
+	}
+	return data, nil
+}
 
-		for _, repoDir := range repoDirs {
+// failedConclusions are the conclusions of runs that count as failures
+var failedConclusions = map[string]bool{
+	"failure":         true,
+	"timed_out":       true,
+	"startup_failure": true,
+}
 
-			repoName := repoDir.Name()
-			repoPath := filepath.Join(ownerPath, repoName)
+// loadWorkflows reads the workflow runs, summarizes the workflows that ran since a time,
+// and finds the failures and recoveries since then
+func loadWorkflows(st store.Store, owner, repo string, since time.Time) ([]Workflow, []WorkflowChange, error) {
+	runs, err := st.WorkflowRuns(owner, repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].CreatedAt.Before(runs[j].CreatedAt)
+	})
 
-			log.Println("process", repoPath)
+	// group oldest first by workflow, which may be renamed
+	var ids []int64
+	byID := map[int64][]github.WorkflowRun{}
+	for _, run := range runs {
+		if _, ok := byID[run.WorkflowID]; !ok {
+			ids = append(ids, run.WorkflowID)
+		}
+		byID[run.WorkflowID] = append(byID[run.WorkflowID], run)
+	}
+
+	workflows, changes := []Workflow{}, []WorkflowChange{}
+	for _, id := range ids {
+		runs := byID[id]
+		latest := runs[len(runs)-1]
+		if latest.CreatedAt.Before(since) {
+			continue
+		}
 
-			data := &RepoData{
-				Owner:  ownerName,
-				Repo:   repoName,
-				Issues: []Issue{},
+		workflow := Workflow{Name: latest.Name, Latest: latest, Health: "running"}
+		failing := false
+		for _, run := range runs {
+			if !run.Completed() {
+				continue
+			}
+			workflow.Latest = run
+			workflow.Health = run.Conclusion
+			switch {
+			case failedConclusions[run.Conclusion]:
+				workflow.Health = "failing"
+				if !failing && !run.CreatedAt.Before(since) {
+					changes = append(changes, WorkflowChange{WorkflowRun: run})
+				}
+				failing = true
+			case run.Conclusion == "success":
+				workflow.Health = "passing"
+				if failing && !run.CreatedAt.Before(since) {
+					changes = append(changes, WorkflowChange{WorkflowRun: run, Recovered: true})
+				}
+				failing = false
 			}
+		}
+		workflows = append(workflows, workflow)
+	}
+	sort.SliceStable(workflows, func(i, j int) bool {
+		if (workflows[i].Health == "failing") != (workflows[j].Health == "failing") {
+			return workflows[i].Health == "failing"
+		}
+		return workflows[i].Name < workflows[j].Name
+	})
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].CreatedAt.Before(changes[j].CreatedAt)
+	})
+	return workflows, changes, nil
+}
 
-			// Process issues.json
-			issues, err := loadIssues(filepath.Join(repoPath, "issues.json"))
-			if err != nil {
-				log.Printf("Warning: failed to load issues for %s: %v", ownerName, err)
+// loadReleases reads the releases published since a time, newest first, with their notes rendered
+func loadReleases(st store.Store, refs *references, owner, repo string, since time.Time) ([]Release, error) {
+	releases, err := st.Releases(owner, repo)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := st.Tags(owner, repo)
+	if err != nil {
+		return nil, err
+	}
+	commits := map[string]string{}
+	for _, tag := range tags {
+		commits[tag.Name] = tag.Commit.SHA
+	}
+
+	loaded := []Release{}
+	for _, release := range releases {
+		if !release.PublishedSince(since) {
+			continue
+		}
+		release.Body = string(mdToHTML([]byte(release.Body), refs))
+		loaded = append(loaded, Release{Release: release, Commit: commits[release.TagName]})
+	}
+	sort.SliceStable(loaded, func(i, j int) bool {
+		return loaded[i].PublishedAt.After(*loaded[j].PublishedAt)
+	})
+	return loaded, nil
+}
+
+// loadDiscussions reads the discussions updated since a time, with their bodies and comments rendered
+func loadDiscussions(st store.Store, refs *references, owner, repo string, since time.Time) ([]Discussion, error) {
+	discussions, err := st.Discussions(owner, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	loaded := []Discussion{}
+	for _, discussion := range discussions {
+		if discussion.UpdatedAt.Before(since) {
+			continue
+		}
+		discussion.Body = string(mdToHTML([]byte(discussion.Body), refs))
+		for i := range discussion.Comments {
+			discussion.Comments[i].Body = string(mdToHTML([]byte(discussion.Comments[i].Body), refs))
+		}
+		loaded = append(loaded, Discussion{
+			Discussion: discussion,
+			New:        !discussion.CreatedAt.Before(since),
+		})
+	}
+	return loaded, nil
+}
+
+// loadBranches reads the commits committed since a time and groups them by branch
+func loadBranches(st store.Store, owner, repo string, since time.Time) ([]BranchActivity, error) {
+	commits, err := st.BranchCommits(owner, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := map[string]*BranchActivity{}
+	for _, commit := range commits {
+		if commit.Commit.Committer.Date.Before(since) {
+			continue
+		}
+		branch, ok := byName[commit.Branch]
+		if !ok {
+			branch = &BranchActivity{Name: commit.Branch}
+			byName[commit.Branch] = branch
+		}
+		branch.Commits = append(branch.Commits, commit)
+		if commit.Direct() {
+			branch.Direct++
+		}
+	}
+
+	branches := []BranchActivity{}
+	for _, branch := range byName {
+		sort.SliceStable(branch.Commits, func(i, j int) bool {
+			return branch.Commits[i].Commit.Committer.Date.After(branch.Commits[j].Commit.Committer.Date)
+		})
+		branches = append(branches, *branch)
+	}
+	sort.Slice(branches, func(i, j int) bool {
+		return branches[i].Name < branches[j].Name
+	})
+	return branches, nil
+}
+
+// loadMostUpvoted reads the most upvoted open issues, leaving out those nobody upvoted
+func loadMostUpvoted(st store.Store, owner, repo string) ([]github.Issue, error) {
+	issues, err := st.MostUpvoted(owner, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	loaded := []github.Issue{}
+	for _, issue := range issues {
+		if issue.Reactions.PlusOne > 0 {
+			loaded = append(loaded, issue)
+		}
+	}
+	sort.SliceStable(loaded, func(i, j int) bool {
+		return loaded[i].Reactions.PlusOne > loaded[j].Reactions.PlusOne
+	})
+	return loaded, nil
+}
+
+// loadIssue reads everything attached to issue from the store and prepares it for the templates
+func loadIssue(st store.Store, refs *references, owner, repo string, issue github.Issue, since time.Time) (Issue, error) {
+	issueData := Issue{Issue: issue}
+
+	var err error
+	if issueData.Comments, err = st.Comments(owner, repo, issue.Number); err != nil {
+		return issueData, err
+	}
+	if issueData.Events, err = st.Events(owner, repo, issue.Number); err != nil {
+		return issueData, err
+	}
+	if issueData.Commits, err = st.Commits(owner, repo, issue.Number); err != nil {
+		return issueData, err
+	}
+	if issueData.PR, err = st.PullRequest(owner, repo, issue.Number); err != nil {
+		return issueData, err
+	}
+	if issueData.Reviews, err = st.Reviews(owner, repo, issue.Number); err != nil {
+		return issueData, err
+	}
+
+	// filter out old events
+	filteredEvents := []github.IssueEvent{}
+	for _, event := range issueData.Events {
+		if !event.CreatedAt.Before(since) {
+			filteredEvents = append(filteredEvents, event)
+		}
+	}
+	issueData.Events = filteredEvents
+
+	// filter out old commits
+	filteredCommits := []github.PullRequestCommit{}
+	for _, commit := range issueData.Commits {
+		if !commit.Commit.Committer.Date.Before(since) {
+			filteredCommits = append(filteredCommits, commit)
+		}
+	}
+	issueData.Commits = filteredCommits
+
+	// render bodies to markdown
+	for _, comment := range issueData.Comments {
+		comment.Body = string(mdToHTML([]byte(comment.Body), refs))
+	}
+
+	// summarize reviews
+	{
+		// find the most recent review for each user
+		type Value struct {
+			State string
+			When  time.Time
+		}
+		states := map[string]*Value{}
+		for _, review := range issueData.Reviews {
+			login := review.User.Login
+
+			if strings.ToLower(review.State) == "pending" {
+				log.Println("skipped pending review", review.ID)
+				continue
 			}
 
-			// process issues subdirectory
-			issuesDir := filepath.Join(repoPath, "issues")
-			for _, issue := range issues {
-				issueDir := filepath.Join(issuesDir, fmt.Sprintf("%d", issue.Number))
-
-				commentsPath := filepath.Join(issueDir, "comments.json")
-				eventsPath := filepath.Join(issueDir, "events.json")
-				commitsPath := filepath.Join(issueDir, "commits.json")
-				prPath := filepath.Join(issueDir, "pr.json")
-				reviewsPath := filepath.Join(issueDir, "reviews.json")
-
-				issueData := Issue{
-					Issue:    issue,
-					Comments: []*github.IssueComment{},
-					Events:   []github.IssueEvent{},
-					Commits:  []github.PullRequestCommit{},
-					Reviews:  []github.PullRequestReview{},
+			newer := &Value{review.State, *review.SubmittedAt}
+			if value, ok := states[login]; ok {
+				if value.When.Before(*review.SubmittedAt) {
+					states[login] = newer
 				}
+			} else {
+				states[login] = newer
+			}
+		}
 
-				if fData, err := os.ReadFile(commentsPath); err == nil {
-					json.Unmarshal(fData, &issueData.Comments)
-				}
-				if fData, err := os.ReadFile(eventsPath); err == nil {
-					json.Unmarshal(fData, &issueData.Events)
-				}
-				if fData, err := os.ReadFile(commitsPath); err == nil {
-					json.Unmarshal(fData, &issueData.Commits)
-				}
-				if fData, err := os.ReadFile(prPath); err == nil {
-					json.Unmarshal(fData, &issueData.PR)
-				}
-				if fData, err := os.ReadFile(reviewsPath); err == nil {
-					json.Unmarshal(fData, &issueData.Reviews)
-				}
+		// count each kind of review state
+		counts := map[string]int{}
+		for _, value := range states {
+			counts[value.State]++
+		}
 
-				// filter out old events
-				filteredEvents := []github.IssueEvent{}
-				for _, event := range issueData.Events {
-					if !event.CreatedAt.Before(config.Since) {
-						filteredEvents = append(filteredEvents, event)
-					}
-				}
-				issueData.Events = filteredEvents
-
-				// filter out old commits
-				filteredCommits := []github.PullRequestCommit{}
-				for _, commit := range issueData.Commits {
-					if !commit.Commit.Committer.Date.Before(config.Since) {
-						filteredCommits = append(filteredCommits, commit)
-					}
-				}
-				issueData.Commits = filteredCommits
+		issueData.ReviewStates = counts
+	}
 
-				// render bodies to markdown
-				for _, comment := range issueData.Comments {
-					comment.Body = string(mdToHTML([]byte(comment.Body)))
-				}
+	switch {
+	case issueData.PR != nil && issueData.PR.Merged:
+		issueData.Status = "merged"
+	case issueData.State == "closed":
+		issueData.Status = "closed"
+	case issueData.PR != nil && issueData.PR.Draft:
+		issueData.Status = "draft"
+	default:
+		issueData.Status = issueData.State
+	}
 
-				// summarize reviews
-				{
-					// find the most recent review for each user
-					type Value struct {
-						State string
-						When  time.Time
-					}
-					states := map[string]*Value{}
-					for _, review := range issueData.Reviews {
-						login := review.User.Login
-
-						if strings.ToLower(review.State) == "pending" {
-							log.Println("skipped pending review", review.ID)
-							continue
-						}
-
-						newer := &Value{review.State, *review.SubmittedAt}
-						if value, ok := states[login]; ok {
-							if value.When.Before(*review.SubmittedAt) {
-								states[login] = newer
-							}
-						} else {
-							states[login] = newer
-						}
-					}
-
-					// count each kind of review state
-					counts := map[string]int{}
-					for _, value := range states {
-						counts[value.State]++
-					}
-
-					// rename review states
-					for state, count := range counts {
-						switch state {
-						case "APPROVED":
-							delete(counts, state)
-							counts["✅"] = count
-						case "CHANGES_REQUESTED":
-							delete(counts, state)
-							counts["🔄"] = count
-						case "COMMENTED":
-							delete(counts, state)
-							counts["💬"] = count
-						case "DISMISSED":
-							delete(counts, state)
-							counts["⊘"] = count
-						}
-					}
-
-					issueData.ReviewStates = counts
-				}
+	return issueData, nil
+}
 
-				data.Issues = append(data.Issues, issueData)
-			}
+// reviewIcon is how a review state is shown on the tags
+func reviewIcon(state string) string {
+	switch state {
+	case "APPROVED":
+		return "✅"
+	case "CHANGES_REQUESTED":
+		return "🔄"
+	case "COMMENTED":
+		return "💬"
+	case "DISMISSED":
+		return "⊘"
+	}
+	return state
+}
 
-			repoData[fmt.Sprintf("%s/%s", ownerName, repoName)] = data
+// reactions shows the nonzero reaction counts as emoji, or nothing without reactions
+func reactions(r github.Reactions) template.HTML {
+	counts := []struct {
+		emoji, name string
+		count       int
+	}{
+		{"👍", "+1", r.PlusOne},
+		{"👎", "-1", r.MinusOne},
+		{"😄", "laugh", r.Laugh},
+		{"🎉", "hooray", r.Hooray},
+		{"😕", "confused", r.Confused},
+		{"❤️", "heart", r.Heart},
+		{"🚀", "rocket", r.Rocket},
+		{"👀", "eyes", r.Eyes},
+	}
 
+	var spans []string
+	for _, c := range counts {
+		if c.count > 0 {
+			spans = append(spans, fmt.Sprintf(`<span class="reaction" title="%s">%s %d</span>`, c.name, c.emoji, c.count))
 		}
 	}
-	// Render the organized data
-	return renderRepoData(repoData, config)
+	if len(spans) == 0 {
+		return ""
+	}
+	return template.HTML(`<span class="reactions">` + strings.Join(spans, " ") + `</span>`)
 }
 
-func renderRepoData(repoData map[string]*RepoData, config Config) error {
-	// Sort repos for consistent output
-	var repoKeys []string
-	for key := range repoData {
-		repoKeys = append(repoKeys, key)
+// duration is d to the second, or nothing if it is 0
+func duration(d time.Duration) string {
+	if d <= 0 {
+		return ""
 	}
-	sort.Strings(repoKeys)
+	return d.Round(time.Second).String()
+}
+
+// byteSize is n bytes in a readable unit
+func byteSize(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, prefix := float64(n)/unit, 0
+	for value >= unit && prefix < len("MGT") {
+		value /= unit
+		prefix++
+	}
+	return fmt.Sprintf("%.1f %cB", value, "kMGT"[prefix])
+}
 
-	tmpl := template.Must(template.New("").Funcs(template.FuncMap{
+// NavRepo is a link in the header
+type NavRepo struct {
+	URL  string
+	Name string
+}
+
+// navReposOf links to the overview and then each repo in repoKeys order
+func navReposOf(repoKeys []string) []NavRepo {
+	navRepos := []NavRepo{{"", "all"}}
+	for _, key := range repoKeys {
+		_, name, _ := strings.Cut(key, "/")
+		navRepos = append(navRepos, NavRepo{key, name})
+	}
+	return navRepos
+}
+
+func parseTemplates(config Config) (*template.Template, error) {
+	tmpl := template.New("").Funcs(template.FuncMap{
 		"safe": func(s string) template.HTML {
 			return template.HTML(s)
 		},
-	}).ParseGlob("templates/*.html"))
+		"sparkline":   sparkline,
+		"reviewIcon":  reviewIcon,
+		"byteSize":    byteSize,
+		"reactions":   reactions,
+		"duration":    duration,
+		"issueAnchor": issueAnchor,
+		"last": func(values []int) int {
+			return values[len(values)-1]
+		},
+	})
+	return parseAssetTemplates(tmpl, config, "*.html")
+}
 
-	os.MkdirAll(config.OutputDir, 0755)
+// executeToFile executes a template into a temporary file and moves it to name,
+// so a server never sees a partially written page
+func executeToFile(tmpl *template.Template, templateName, name string, data any) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
 
-	outputFile, err := os.Create(filepath.Join(config.OutputDir, "index.html"))
+	f, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+"-*")
 	if err != nil {
-		log.Fatal("Error creating output file:", err)
+		return err
 	}
-	defer outputFile.Close()
+	defer os.Remove(f.Name())
 
-	type NavRepo struct {
-		URL  string
-		Name string
+	if err := tmpl.ExecuteTemplate(f, templateName, data); err != nil {
+		f.Close()
+		return fmt.Errorf("executing %s: %w", templateName, err)
 	}
-	navRepos := []NavRepo{{"", "all"}}
-	for _, repo := range repoData {
-		navRepos = append(navRepos, NavRepo{fmt.Sprintf("%s/%s", repo.Owner, repo.Repo), repo.Repo})
+	if err := f.Close(); err != nil {
+		return err
 	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
 
-	// Execute the template with data
-	err = tmpl.ExecuteTemplate(outputFile, "index.html", map[string]any{
-		"Repos":       repoData,
-		"CurrentYear": time.Now().Year(),
-		"BuildDate":   time.Now().UTC().Format("2006-01-02T15:04:05.000Z"),
+// renderRepoPage writes owner/repo/index.html
+func renderRepoPage(tmpl *template.Template, repo *RepoData, navRepos []NavRepo, theme Theme, config Config, buildDate time.Time) error {
+	outputPath := filepath.Join(config.OutputDir, repo.Owner, repo.Repo, "index.html")
+	return executeToFile(tmpl, "repo.html", outputPath, map[string]any{
+		"Repo":        repo,
+		"Theme":       theme,
+		"CurrentYear": buildDate.Year(),
+		"BuildDate":   buildDate.UTC().Format("2006-01-02T15:04:05.000Z"),
 		"NavRepos":    navRepos,
 		"SiteRoot":    config.SiteRoot,
 		"Since":       config.Since.UTC().Format("2006-01-02T15:04:05.000Z"),
 	})
+}
+
+// renderIndexPage writes index.html, which summarizes repos
+func renderIndexPage(tmpl *template.Template, repos []*RepoData, navRepos []NavRepo, theme Theme, config Config, buildDate time.Time) error {
+	return executeToFile(tmpl, "index.html", filepath.Join(config.OutputDir, "index.html"), map[string]any{
+		"Repos":       repos,
+		"Theme":       theme,
+		"CurrentYear": buildDate.Year(),
+		"BuildDate":   buildDate.UTC().Format("2006-01-02T15:04:05.000Z"),
+		"NavRepos":    navRepos,
+		"SiteRoot":    config.SiteRoot,
+		"Since":       config.Since.UTC().Format("2006-01-02T15:04:05.000Z"),
+	})
+}
+
+func renderRepoData(repoData map[string]*RepoData, config Config) error {
+	// Sort repos for consistent output
+	var repoKeys []string
+	for key := range repoData {
+		repoKeys = append(repoKeys, key)
+	}
+	sort.Strings(repoKeys)
+
+	tmpl, err := parseTemplates(config)
 	if err != nil {
-		log.Fatal("Error executing template:", err)
+		return err
+	}
+	theme, err := loadTheme(config)
+	if err != nil {
+		return err
 	}
 
-	for _, repo := range repoData {
+	buildDate := config.Clock.Now()
+	navRepos := navReposOf(repoKeys)
 
-		outputPath := filepath.Join(config.OutputDir, repo.Owner, repo.Repo, "index.html")
-		os.MkdirAll(filepath.Dir(outputPath), 0755)
-		outputFile, err := os.Create(outputPath)
-		if err != nil {
-			log.Fatal("Error creating output file:", err)
-		}
-		defer outputFile.Close()
-
-		err = tmpl.ExecuteTemplate(outputFile, "repo.html", map[string]any{
-			"Repo":        repo,
-			"CurrentYear": time.Now().Year(),
-			"BuildDate":   time.Now().UTC().Format("2006-01-02T15:04:05.000Z"),
-			"NavRepos":    navRepos,
-			"SiteRoot":    config.SiteRoot,
-			"Since":       config.Since.UTC().Format("2006-01-02T15:04:05.000Z"),
-		})
-		if err != nil {
-			log.Fatal("Error executing template:", err)
+	// in the same order as the navigation
+	var repos []*RepoData
+	for _, key := range repoKeys {
+		repos = append(repos, repoData[key])
+	}
+
+	if err := renderIndexPage(tmpl, repos, navRepos, theme, config, buildDate); err != nil {
+		return err
+	}
+
+	for _, key := range repoKeys {
+		if err := renderRepoPage(tmpl, repoData[key], navRepos, theme, config, buildDate); err != nil {
+			return err
 		}
+	}
 
+	if err := renderAPI(repoKeys, repoData, config, buildDate); err != nil {
+		return err
 	}
 
 	outputStaticDir := filepath.Join(config.OutputDir, "static")
@@ -304,9 +739,9 @@ func renderRepoData(repoData map[string]*RepoData, config Config) error {
 	os.RemoveAll(outputStaticDir)
 
 	log.Println("static ->", outputStaticDir)
-	if err := os.CopyFS(outputStaticDir, os.DirFS("static")); err != nil {
-		log.Fatal(err)
+	if err := copyStatic(config, outputStaticDir); err != nil {
+		return err
 	}
 
-	return nil
+	return precompress(config.OutputDir)
 }
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite testdata/golden with the render output")
+
+// goldenDir holds the expected render output of testdata/data and testdata/history
+const goldenDir = "testdata/golden"
+
+// goldenExts are the rendered files compared with golden files; static files are copies and compressed ones are derived
+var goldenExts = map[string]bool{
+	".html": true,
+	".json": true,
+}
+
+// goldenFiles reads the compared files under dir, keyed by slash-separated path
+func goldenFiles(t *testing.T, dir string) map[string][]byte {
+	t.Helper()
+	files := map[string][]byte{}
+	err := filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, name)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if rel == "static" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !goldenExts[filepath.Ext(name)] {
+			return nil
+		}
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		files[rel] = data
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+// firstDifference describes the first line where want and got differ
+func firstDifference(want, got []byte) string {
+	wantLines := strings.Split(string(want), "\n")
+	gotLines := strings.Split(string(got), "\n")
+	for i := 0; i < len(wantLines) || i < len(gotLines); i++ {
+		var wantLine, gotLine string
+		if i < len(wantLines) {
+			wantLine = wantLines[i]
+		}
+		if i < len(gotLines) {
+			gotLine = gotLines[i]
+		}
+		if wantLine != gotLine {
+			return fmt.Sprintf("at line %d: want %q, got %q", i+1, strings.TrimSpace(wantLine), strings.TrimSpace(gotLine))
+		}
+	}
+	return ""
+}
+
+// goldenConfig renders testdata/data and testdata/history into a temporary directory at a fixed time
+func goldenConfig(t *testing.T) Config {
+	t.Helper()
+	config := defaultConfig()
+	config.FetchDir = filepath.Join("testdata", "data")
+	config.HistoryDir = filepath.Join("testdata", "history")
+	config.OutputDir = t.TempDir()
+	config.Clock = fixedClock(time.Date(2025, 6, 4, 12, 0, 0, 0, time.UTC))
+	config.Since = sinceWorkdays(config.Clock.Now(), config.Workdays)
+	return config
+}
+
+// TestRenderGolden compares the pages and API documents with testdata/golden.
+// After an intended change to the output, run go test -update and review the diff.
+func TestRenderGolden(t *testing.T) {
+	config := goldenConfig(t)
+	if err := render(config); err != nil {
+		t.Fatal(err)
+	}
+	got := goldenFiles(t, config.OutputDir)
+
+	if *update {
+		if err := os.RemoveAll(goldenDir); err != nil {
+			t.Fatal(err)
+		}
+		for name, data := range got {
+			target := filepath.Join(goldenDir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(target, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return
+	}
+
+	want := goldenFiles(t, goldenDir)
+	for name, wantData := range want {
+		gotData, ok := got[name]
+		if !ok {
+			t.Errorf("%s was not rendered", name)
+		} else if !bytes.Equal(gotData, wantData) {
+			t.Errorf("%s differs %s", name, firstDifference(wantData, gotData))
+		}
+	}
+	for name := range got {
+		if _, ok := want[name]; !ok {
+			t.Errorf("%s has no golden file", name)
+		}
+	}
+}
+
+// TestRenderLinksToGitHubEnterprise checks that the links to GitHub follow --github-base-url
+func TestRenderLinksToGitHubEnterprise(t *testing.T) {
+	config := goldenConfig(t)
+	config.GitHubBaseURL = "https://ghes.example.com/api/v3"
+	if err := render(config); err != nil {
+		t.Fatal(err)
+	}
+	page, err := os.ReadFile(filepath.Join(config.OutputDir, "kokkos", "kokkos", "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`href="https://ghes.example.com/kokkos/kokkos/archive/refs/tags/4.6.01.tar.gz"`,                 // release
+		`href="https://ghes.example.com/kokkos/kokkos/commit/d4e5f60718293a4b5c6d7e8f90123456789abcde"`, // branch commit
+		`href="https://ghes.example.com/kokkos/kokkos/pull/98"`,                                         // pull request of a branch commit
+	} {
+		if !strings.Contains(string(page), want) {
+			t.Errorf("repo page lacks %s", want)
+		}
+	}
+	for _, hardCoded := range []string{
+		`href="https://github.com/kokkos/kokkos/archive`,
+		`href="https://github.com/kokkos/kokkos/commit/d4e5f607`,
+		`href="https://github.com/kokkos/kokkos/pull/98"`,
+	} {
+		if strings.Contains(string(page), hardCoded) {
+			t.Errorf("repo page has %s", hardCoded)
+		}
+	}
+}
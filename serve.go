@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"kokkos-dashboard/metrics"
+)
+
+// refreshStatus is reported at /status
+type refreshStatus struct {
+	Running     bool      `json:"running"`
+	Root        string    `json:"root"`
+	Interval    string    `json:"interval,omitempty"`
+	LastStart   time.Time `json:"last_start,omitzero"`
+	LastEnd     time.Time `json:"last_end,omitzero"`
+	LastSuccess time.Time `json:"last_success,omitzero"`
+	Duration    string    `json:"duration,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	NextRefresh time.Time `json:"next_refresh,omitzero"`
+}
+
+// refresher serves the rendered site and periodically replaces it.
+// Each refresh fetches and renders into a new directory, which is swapped in once it is complete.
+type refresher struct {
+	config   Config
+	interval time.Duration
+	root     atomic.Pointer[string]
+
+	// held while the store or the served directory are being written
+	work sync.Mutex
+
+	mu     sync.Mutex
+	status refreshStatus
+}
+
+func newRefresher(config Config, interval time.Duration) *refresher {
+	r := &refresher{config: config, interval: interval}
+	r.setRoot(config.OutputDir)
+	if interval > 0 {
+		r.status.Interval = interval.String()
+	}
+	return r
+}
+
+func (r *refresher) setRoot(root string) {
+	r.root.Store(&root)
+	r.mu.Lock()
+	r.status.Root = root
+	r.mu.Unlock()
+}
+
+// run refreshes immediately and then every interval until ctx is done
+func (r *refresher) run(ctx context.Context) {
+	if r.interval <= 0 {
+		return
+	}
+	for {
+		if err := r.refresh(); err != nil {
+			log.Printf("refresh error: %v", err)
+		}
+
+		r.mu.Lock()
+		r.status.NextRefresh = time.Now().Add(r.interval)
+		r.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.interval):
+		}
+	}
+}
+
+// refresh fetches and renders into a new generation directory and swaps it in
+func (r *refresher) refresh() error {
+	start := time.Now()
+	r.mu.Lock()
+	r.status.Running = true
+	r.status.LastStart = start
+	r.mu.Unlock()
+
+	r.work.Lock()
+	err := r.build(start)
+	r.work.Unlock()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status.Running = false
+	r.status.LastEnd = time.Now()
+	r.status.Duration = r.status.LastEnd.Sub(start).Round(time.Millisecond).String()
+	if err != nil {
+		r.status.Error = err.Error()
+	} else {
+		r.status.Error = ""
+		r.status.LastSuccess = r.status.LastEnd
+	}
+	return err
+}
+
+// generationDir is where a refresh that started at start renders to
+func generationDir(outputDir string, start time.Time) string {
+	return fmt.Sprintf("%s-%d", filepath.Clean(outputDir), start.Unix())
+}
+
+// removeGenerations removes the generation directories of an earlier serve.
+// A refresh cut short by a shutdown leaves its directory half-written, and nothing else would remove it.
+func removeGenerations(outputDir string) {
+	names, err := filepath.Glob(filepath.Clean(outputDir) + "-*")
+	if err != nil {
+		log.Printf("find old generations: %v", err)
+		return
+	}
+	prefix := filepath.Clean(outputDir) + "-"
+	for _, name := range names {
+		if _, err := strconv.ParseInt(strings.TrimPrefix(name, prefix), 10, 64); err != nil {
+			continue // not ours
+		}
+		log.Println("remove", name)
+		if err := os.RemoveAll(name); err != nil {
+			log.Printf("remove old generation: %v", err)
+		}
+	}
+}
+
+func (r *refresher) build(start time.Time) error {
+	config := r.config
+	config.Since = sinceWorkdays(config.Clock.Now(), config.Workdays)
+	config.OutputDir = generationDir(r.config.OutputDir, start)
+
+	log.Println("refresh into", config.OutputDir)
+	if err := fetch(config); err != nil {
+		os.RemoveAll(config.OutputDir)
+		return fmt.Errorf("fetch: %w", err)
+	}
+	if err := render(config); err != nil {
+		os.RemoveAll(config.OutputDir)
+		return fmt.Errorf("render: %w", err)
+	}
+
+	previous := *r.root.Load()
+	r.setRoot(config.OutputDir)
+	log.Println("serving", config.OutputDir)
+
+	// the configured output dir is left alone, older generations are ours to remove
+	if previous != r.config.OutputDir {
+		log.Println("remove", previous)
+		os.RemoveAll(previous)
+	}
+	return nil
+}
+
+func (r *refresher) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	fileServer{
+		root:     func() string { return *r.root.Load() },
+		siteRoot: r.config.SiteRoot,
+	}.ServeHTTP(w, req)
+}
+
+// serveReady succeeds once there is a rendered site to serve
+func (r *refresher) serveReady(w http.ResponseWriter, req *http.Request) {
+	if _, err := os.Stat(filepath.Join(*r.root.Load(), "index.html")); err != nil {
+		http.Error(w, "no rendered site yet", http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+func (r *refresher) serveStatus(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	status := r.status
+	r.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// serve serves the output directory until SIGINT or SIGTERM.
+// With a positive refreshInterval, it also keeps the site up to date.
+func serve(config Config, refreshInterval time.Duration) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	r := newRefresher(config, refreshInterval)
+	removeGenerations(config.OutputDir)
+
+	mux := http.NewServeMux()
+	mux.Handle("/", r)
+	mux.HandleFunc("/status", r.serveStatus)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/readyz", r.serveReady)
+	mux.Handle("/metrics", metrics.Default.Handler())
+	if config.WebhookSecret != "" {
+		mux.HandleFunc("/webhook", r.serveWebhook)
+	}
+
+	go r.run(ctx)
+
+	return listenAndServe(ctx, mux, config)
+}
+
+// listenAndServe serves handler on config.ListenAddr until ctx is done, then shuts down gracefully
+func listenAndServe(ctx context.Context, handler http.Handler, config Config) error {
+	srv := &http.Server{Addr: config.ListenAddr, Handler: handler}
+
+	errc := make(chan error, 1)
+	go func() {
+		if config.TLSCert != "" || config.TLSKey != "" {
+			log.Printf("serve https on %s%s", srv.Addr, config.SiteRoot)
+			errc <- srv.ListenAndServeTLS(config.TLSCert, config.TLSKey)
+		} else {
+			log.Printf("serve on %s%s", srv.Addr, config.SiteRoot)
+			errc <- srv.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Println("shutting down...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
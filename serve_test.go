@@ -0,0 +1,32 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRemoveGenerations(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "public")
+	unfinished := generationDir(output, time.Unix(1748952000, 0))
+	for _, name := range []string{output, unfinished, output + "-old", filepath.Join(dir, "public2-1748952000")} {
+		if err := os.MkdirAll(filepath.Join(name, "kokkos"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removeGenerations(output + "/")
+
+	for name, kept := range map[string]bool{
+		output:                                   true,
+		unfinished:                               false,
+		output + "-old":                          true,
+		filepath.Join(dir, "public2-1748952000"): true,
+	} {
+		if _, err := os.Stat(name); (err == nil) != kept {
+			t.Errorf("%s: kept %v, want %v", name, err == nil, kept)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"kokkos-dashboard/github"
+)
+
+// FS stores data as JSON files:
+//
+//	<root>/<owner>/<repo>/{issues,releases,tags,discussions,most_upvoted,workflow_runs,branch_commits}.json
+//	<root>/<owner>/<repo>/issues/<number>/{comments,events,commits,pr,reviews}.json
+type FS struct {
+	root string
+}
+
+// NewFS creates a filesystem store rooted at root
+func NewFS(root string) *FS {
+	return &FS{root: root}
+}
+
+func (s *FS) repoDir(owner, repo string) string {
+	return filepath.Join(s.root, owner, repo)
+}
+
+func (s *FS) issueDir(owner, repo string, number int) string {
+	return filepath.Join(s.repoDir(owner, repo), "issues", fmt.Sprintf("%d", number))
+}
+
+func (s *FS) write(v any, name string) error {
+	err := os.MkdirAll(filepath.Dir(name), 0755)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	log.Printf("write %d to %s", len(data), name)
+	return os.WriteFile(name, data, 0644)
+}
+
+// read decodes name into v, leaving v untouched if name does not exist
+func (s *FS) read(name string, v any) error {
+	data, err := os.ReadFile(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func (s *FS) Reset() error {
+	log.Println("remove", s.root)
+	return os.RemoveAll(s.root)
+}
+
+func (s *FS) Close() error {
+	return nil
+}
+
+func (s *FS) PutIssues(owner, repo string, issues []github.Issue) error {
+	return s.write(issues, filepath.Join(s.repoDir(owner, repo), "issues.json"))
+}
+
+func (s *FS) PutIssue(owner, repo string, issue github.Issue) error {
+	issues, err := s.ListIssues(owner, repo)
+	if err != nil {
+		return err
+	}
+	return s.PutIssues(owner, repo, upsertIssue(issues, issue))
+}
+
+func (s *FS) PutComments(owner, repo string, number int, comments []github.IssueComment) error {
+	return s.write(comments, filepath.Join(s.issueDir(owner, repo, number), "comments.json"))
+}
+
+func (s *FS) PutEvents(owner, repo string, number int, events []github.IssueEvent) error {
+	return s.write(events, filepath.Join(s.issueDir(owner, repo, number), "events.json"))
+}
+
+func (s *FS) PutCommits(owner, repo string, number int, commits []github.PullRequestCommit) error {
+	return s.write(commits, filepath.Join(s.issueDir(owner, repo, number), "commits.json"))
+}
+
+func (s *FS) PutPullRequest(owner, repo string, pr *github.PullRequest) error {
+	return s.write(pr, filepath.Join(s.issueDir(owner, repo, pr.Number), "pr.json"))
+}
+
+func (s *FS) PutReviews(owner, repo string, number int, reviews []github.PullRequestReview) error {
+	return s.write(reviews, filepath.Join(s.issueDir(owner, repo, number), "reviews.json"))
+}
+
+func (s *FS) PutReleases(owner, repo string, releases []github.Release) error {
+	return s.write(releases, filepath.Join(s.repoDir(owner, repo), "releases.json"))
+}
+
+func (s *FS) PutTags(owner, repo string, tags []github.Tag) error {
+	return s.write(tags, filepath.Join(s.repoDir(owner, repo), "tags.json"))
+}
+
+func (s *FS) PutDiscussions(owner, repo string, discussions []github.Discussion) error {
+	return s.write(discussions, filepath.Join(s.repoDir(owner, repo), "discussions.json"))
+}
+
+func (s *FS) PutMostUpvoted(owner, repo string, issues []github.Issue) error {
+	return s.write(issues, filepath.Join(s.repoDir(owner, repo), "most_upvoted.json"))
+}
+
+func (s *FS) PutWorkflowRuns(owner, repo string, runs []github.WorkflowRun) error {
+	return s.write(runs, filepath.Join(s.repoDir(owner, repo), "workflow_runs.json"))
+}
+
+func (s *FS) PutBranchCommits(owner, repo string, commits []github.BranchCommit) error {
+	return s.write(commits, filepath.Join(s.repoDir(owner, repo), "branch_commits.json"))
+}
+
+func (s *FS) ListRepos() ([]Repo, error) {
+	ownerDirs, err := os.ReadDir(s.root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data directory: %w", err)
+	}
+
+	repos := []Repo{}
+	for _, ownerDir := range ownerDirs {
+		if !ownerDir.IsDir() {
+			continue
+		}
+
+		repoDirs, err := os.ReadDir(filepath.Join(s.root, ownerDir.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, repoDir := range repoDirs {
+			if repoDir.IsDir() {
+				repos = append(repos, Repo{Owner: ownerDir.Name(), Name: repoDir.Name()})
+			}
+		}
+	}
+	return repos, nil
+}
+
+func (s *FS) ListIssues(owner, repo string) ([]github.Issue, error) {
+	issues := []github.Issue{}
+	err := s.read(filepath.Join(s.repoDir(owner, repo), "issues.json"), &issues)
+	return sortIssues(issues), err
+}
+
+func (s *FS) Comments(owner, repo string, number int) ([]*github.IssueComment, error) {
+	comments := []*github.IssueComment{}
+	err := s.read(filepath.Join(s.issueDir(owner, repo, number), "comments.json"), &comments)
+	return comments, err
+}
+
+func (s *FS) Events(owner, repo string, number int) ([]github.IssueEvent, error) {
+	events := []github.IssueEvent{}
+	err := s.read(filepath.Join(s.issueDir(owner, repo, number), "events.json"), &events)
+	return events, err
+}
+
+func (s *FS) Commits(owner, repo string, number int) ([]github.PullRequestCommit, error) {
+	commits := []github.PullRequestCommit{}
+	err := s.read(filepath.Join(s.issueDir(owner, repo, number), "commits.json"), &commits)
+	return commits, err
+}
+
+func (s *FS) PullRequest(owner, repo string, number int) (*github.PullRequest, error) {
+	var pr *github.PullRequest
+	err := s.read(filepath.Join(s.issueDir(owner, repo, number), "pr.json"), &pr)
+	return pr, err
+}
+
+func (s *FS) Reviews(owner, repo string, number int) ([]github.PullRequestReview, error) {
+	reviews := []github.PullRequestReview{}
+	err := s.read(filepath.Join(s.issueDir(owner, repo, number), "reviews.json"), &reviews)
+	return reviews, err
+}
+
+func (s *FS) Releases(owner, repo string) ([]github.Release, error) {
+	releases := []github.Release{}
+	err := s.read(filepath.Join(s.repoDir(owner, repo), "releases.json"), &releases)
+	return releases, err
+}
+
+func (s *FS) Tags(owner, repo string) ([]github.Tag, error) {
+	tags := []github.Tag{}
+	err := s.read(filepath.Join(s.repoDir(owner, repo), "tags.json"), &tags)
+	return tags, err
+}
+
+func (s *FS) Discussions(owner, repo string) ([]github.Discussion, error) {
+	discussions := []github.Discussion{}
+	err := s.read(filepath.Join(s.repoDir(owner, repo), "discussions.json"), &discussions)
+	return discussions, err
+}
+
+func (s *FS) MostUpvoted(owner, repo string) ([]github.Issue, error) {
+	issues := []github.Issue{}
+	err := s.read(filepath.Join(s.repoDir(owner, repo), "most_upvoted.json"), &issues)
+	return issues, err
+}
+
+func (s *FS) WorkflowRuns(owner, repo string) ([]github.WorkflowRun, error) {
+	runs := []github.WorkflowRun{}
+	err := s.read(filepath.Join(s.repoDir(owner, repo), "workflow_runs.json"), &runs)
+	return runs, err
+}
+
+func (s *FS) BranchCommits(owner, repo string) ([]github.BranchCommit, error) {
+	commits := []github.BranchCommit{}
+	err := s.read(filepath.Join(s.repoDir(owner, repo), "branch_commits.json"), &commits)
+	return commits, err
+}
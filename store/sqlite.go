@@ -0,0 +1,274 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+
+	"kokkos-dashboard/github"
+
+	_ "modernc.org/sqlite"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS issues (
+	owner      TEXT NOT NULL,
+	repo       TEXT NOT NULL,
+	number     INTEGER NOT NULL,
+	updated_at TEXT NOT NULL,
+	data       TEXT NOT NULL,
+	PRIMARY KEY (owner, repo, number)
+);
+CREATE TABLE IF NOT EXISTS documents (
+	owner  TEXT NOT NULL,
+	repo   TEXT NOT NULL,
+	number INTEGER NOT NULL,
+	kind   TEXT NOT NULL,
+	data   TEXT NOT NULL,
+	PRIMARY KEY (owner, repo, number, kind)
+);
+`
+
+// repoDocument is the number of documents that belong to a repository rather than an issue
+const repoDocument = 0
+
+// SQLite stores data in a single SQLite database.
+// Issues get their own table so they can be listed, everything attached to an issue is a JSON document.
+// Documents about the whole repository, like its releases, have number repoDocument.
+type SQLite struct {
+	db *sql.DB
+}
+
+// OpenSQLite opens or creates the database at path
+func OpenSQLite(path string) (*SQLite, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLite{db: db}, nil
+}
+
+func (s *SQLite) putDocument(owner, repo string, number int, kind string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	log.Printf("write %d to %s/%s#%d %s", len(data), owner, repo, number, kind)
+	_, err = s.db.Exec(`INSERT OR REPLACE INTO documents (owner, repo, number, kind, data) VALUES (?, ?, ?, ?, ?)`,
+		owner, repo, number, kind, string(data))
+	return err
+}
+
+// getDocument decodes a document into v, leaving v untouched if there is none
+func (s *SQLite) getDocument(owner, repo string, number int, kind string, v any) error {
+	var data string
+	err := s.db.QueryRow(`SELECT data FROM documents WHERE owner = ? AND repo = ? AND number = ? AND kind = ?`,
+		owner, repo, number, kind).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(data), v)
+}
+
+func (s *SQLite) Reset() error {
+	log.Println("clear sqlite store")
+	_, err := s.db.Exec(`DELETE FROM issues; DELETE FROM documents;`)
+	return err
+}
+
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLite) PutIssues(owner, repo string, issues []github.Issue) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM issues WHERE owner = ? AND repo = ?`, owner, repo); err != nil {
+		return err
+	}
+	for _, issue := range issues {
+		if err := insertIssue(tx, owner, repo, issue); err != nil {
+			return err
+		}
+	}
+	log.Printf("write %d issues to %s/%s", len(issues), owner, repo)
+	return tx.Commit()
+}
+
+func (s *SQLite) PutIssue(owner, repo string, issue github.Issue) error {
+	return insertIssue(s.db, owner, repo, issue)
+}
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func insertIssue(db execer, owner, repo string, issue github.Issue) error {
+	data, err := json.Marshal(issue)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`INSERT OR REPLACE INTO issues (owner, repo, number, updated_at, data) VALUES (?, ?, ?, ?, ?)`,
+		owner, repo, issue.Number, issue.UpdatedAt.UTC().Format("2006-01-02T15:04:05Z"), string(data))
+	return err
+}
+
+func (s *SQLite) PutComments(owner, repo string, number int, comments []github.IssueComment) error {
+	return s.putDocument(owner, repo, number, "comments", comments)
+}
+
+func (s *SQLite) PutEvents(owner, repo string, number int, events []github.IssueEvent) error {
+	return s.putDocument(owner, repo, number, "events", events)
+}
+
+func (s *SQLite) PutCommits(owner, repo string, number int, commits []github.PullRequestCommit) error {
+	return s.putDocument(owner, repo, number, "commits", commits)
+}
+
+func (s *SQLite) PutPullRequest(owner, repo string, pr *github.PullRequest) error {
+	return s.putDocument(owner, repo, pr.Number, "pr", pr)
+}
+
+func (s *SQLite) PutReviews(owner, repo string, number int, reviews []github.PullRequestReview) error {
+	return s.putDocument(owner, repo, number, "reviews", reviews)
+}
+
+func (s *SQLite) PutReleases(owner, repo string, releases []github.Release) error {
+	return s.putDocument(owner, repo, repoDocument, "releases", releases)
+}
+
+func (s *SQLite) PutTags(owner, repo string, tags []github.Tag) error {
+	return s.putDocument(owner, repo, repoDocument, "tags", tags)
+}
+
+func (s *SQLite) PutDiscussions(owner, repo string, discussions []github.Discussion) error {
+	return s.putDocument(owner, repo, repoDocument, "discussions", discussions)
+}
+
+func (s *SQLite) PutMostUpvoted(owner, repo string, issues []github.Issue) error {
+	return s.putDocument(owner, repo, repoDocument, "most_upvoted", issues)
+}
+
+func (s *SQLite) PutWorkflowRuns(owner, repo string, runs []github.WorkflowRun) error {
+	return s.putDocument(owner, repo, repoDocument, "workflow_runs", runs)
+}
+
+func (s *SQLite) PutBranchCommits(owner, repo string, commits []github.BranchCommit) error {
+	return s.putDocument(owner, repo, repoDocument, "branch_commits", commits)
+}
+
+func (s *SQLite) ListRepos() ([]Repo, error) {
+	rows, err := s.db.Query(`SELECT owner, repo FROM issues UNION SELECT owner, repo FROM documents ORDER BY owner, repo`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	repos := []Repo{}
+	for rows.Next() {
+		var r Repo
+		if err := rows.Scan(&r.Owner, &r.Name); err != nil {
+			return nil, err
+		}
+		repos = append(repos, r)
+	}
+	return repos, rows.Err()
+}
+
+func (s *SQLite) ListIssues(owner, repo string) ([]github.Issue, error) {
+	rows, err := s.db.Query(`SELECT data FROM issues WHERE owner = ? AND repo = ? ORDER BY updated_at DESC, number DESC`, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	issues := []github.Issue{}
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var issue github.Issue
+		if err := json.Unmarshal([]byte(data), &issue); err != nil {
+			return nil, err
+		}
+		issues = append(issues, issue)
+	}
+	return issues, rows.Err()
+}
+
+func (s *SQLite) Comments(owner, repo string, number int) ([]*github.IssueComment, error) {
+	comments := []*github.IssueComment{}
+	err := s.getDocument(owner, repo, number, "comments", &comments)
+	return comments, err
+}
+
+func (s *SQLite) Events(owner, repo string, number int) ([]github.IssueEvent, error) {
+	events := []github.IssueEvent{}
+	err := s.getDocument(owner, repo, number, "events", &events)
+	return events, err
+}
+
+func (s *SQLite) Commits(owner, repo string, number int) ([]github.PullRequestCommit, error) {
+	commits := []github.PullRequestCommit{}
+	err := s.getDocument(owner, repo, number, "commits", &commits)
+	return commits, err
+}
+
+func (s *SQLite) PullRequest(owner, repo string, number int) (*github.PullRequest, error) {
+	var pr *github.PullRequest
+	err := s.getDocument(owner, repo, number, "pr", &pr)
+	return pr, err
+}
+
+func (s *SQLite) Reviews(owner, repo string, number int) ([]github.PullRequestReview, error) {
+	reviews := []github.PullRequestReview{}
+	err := s.getDocument(owner, repo, number, "reviews", &reviews)
+	return reviews, err
+}
+
+func (s *SQLite) Releases(owner, repo string) ([]github.Release, error) {
+	releases := []github.Release{}
+	err := s.getDocument(owner, repo, repoDocument, "releases", &releases)
+	return releases, err
+}
+
+func (s *SQLite) Tags(owner, repo string) ([]github.Tag, error) {
+	tags := []github.Tag{}
+	err := s.getDocument(owner, repo, repoDocument, "tags", &tags)
+	return tags, err
+}
+
+func (s *SQLite) Discussions(owner, repo string) ([]github.Discussion, error) {
+	discussions := []github.Discussion{}
+	err := s.getDocument(owner, repo, repoDocument, "discussions", &discussions)
+	return discussions, err
+}
+
+func (s *SQLite) MostUpvoted(owner, repo string) ([]github.Issue, error) {
+	issues := []github.Issue{}
+	err := s.getDocument(owner, repo, repoDocument, "most_upvoted", &issues)
+	return issues, err
+}
+
+func (s *SQLite) WorkflowRuns(owner, repo string) ([]github.WorkflowRun, error) {
+	runs := []github.WorkflowRun{}
+	err := s.getDocument(owner, repo, repoDocument, "workflow_runs", &runs)
+	return runs, err
+}
+
+func (s *SQLite) BranchCommits(owner, repo string) ([]github.BranchCommit, error) {
+	commits := []github.BranchCommit{}
+	err := s.getDocument(owner, repo, repoDocument, "branch_commits", &commits)
+	return commits, err
+}
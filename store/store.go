@@ -0,0 +1,85 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+
+	"kokkos-dashboard/github"
+)
+
+// Repo identifies a repository that has data in a Store
+type Repo struct {
+	Owner string
+	Name  string
+}
+
+// Store holds fetched GitHub data between fetch and render.
+// Getters return empty results without an error when nothing was stored.
+type Store interface {
+	// Reset removes all stored data
+	Reset() error
+	Close() error
+
+	PutIssues(owner, repo string, issues []github.Issue) error
+	PutIssue(owner, repo string, issue github.Issue) error
+	PutComments(owner, repo string, number int, comments []github.IssueComment) error
+	PutEvents(owner, repo string, number int, events []github.IssueEvent) error
+	PutCommits(owner, repo string, number int, commits []github.PullRequestCommit) error
+	PutPullRequest(owner, repo string, pr *github.PullRequest) error
+	PutReviews(owner, repo string, number int, reviews []github.PullRequestReview) error
+	PutReleases(owner, repo string, releases []github.Release) error
+	PutTags(owner, repo string, tags []github.Tag) error
+	PutDiscussions(owner, repo string, discussions []github.Discussion) error
+	PutMostUpvoted(owner, repo string, issues []github.Issue) error
+	PutWorkflowRuns(owner, repo string, runs []github.WorkflowRun) error
+	PutBranchCommits(owner, repo string, commits []github.BranchCommit) error
+
+	ListRepos() ([]Repo, error)
+	// ListIssues returns the most recently updated issues first, and of those updated at once the highest number
+	ListIssues(owner, repo string) ([]github.Issue, error)
+	Comments(owner, repo string, number int) ([]*github.IssueComment, error)
+	Events(owner, repo string, number int) ([]github.IssueEvent, error)
+	Commits(owner, repo string, number int) ([]github.PullRequestCommit, error)
+	PullRequest(owner, repo string, number int) (*github.PullRequest, error)
+	Reviews(owner, repo string, number int) ([]github.PullRequestReview, error)
+	Releases(owner, repo string) ([]github.Release, error)
+	Tags(owner, repo string) ([]github.Tag, error)
+	Discussions(owner, repo string) ([]github.Discussion, error)
+	MostUpvoted(owner, repo string) ([]github.Issue, error)
+	WorkflowRuns(owner, repo string) ([]github.WorkflowRun, error)
+	BranchCommits(owner, repo string) ([]github.BranchCommit, error)
+}
+
+// Open returns the Store of the given kind ("fs" or "sqlite") rooted at path
+func Open(kind, path string) (Store, error) {
+	switch kind {
+	case "", "fs":
+		return NewFS(path), nil
+	case "sqlite":
+		return OpenSQLite(path)
+	default:
+		return nil, fmt.Errorf("unknown store %q", kind)
+	}
+}
+
+// upsertIssue replaces the issue with the same number, or prepends it
+func upsertIssue(issues []github.Issue, issue github.Issue) []github.Issue {
+	for i := range issues {
+		if issues[i].Number == issue.Number {
+			issues[i] = issue
+			return issues
+		}
+	}
+	return append([]github.Issue{issue}, issues...)
+}
+
+// sortIssues puts issues in the order of ListIssues
+func sortIssues(issues []github.Issue) []github.Issue {
+	sort.Slice(issues, func(i, j int) bool {
+		if !issues[i].UpdatedAt.Equal(issues[j].UpdatedAt) {
+			return issues[i].UpdatedAt.After(issues[j].UpdatedAt)
+		}
+		return issues[i].Number > issues[j].Number
+	})
+	return issues
+}
@@ -0,0 +1,148 @@
+package store
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"kokkos-dashboard/github"
+)
+
+// openStores opens an empty store of each kind
+func openStores(t *testing.T) map[string]Store {
+	t.Helper()
+	stores := map[string]Store{}
+	for kind, path := range map[string]string{
+		"fs":     t.TempDir(),
+		"sqlite": filepath.Join(t.TempDir(), "dashboard.db"),
+	} {
+		st, err := Open(kind, path)
+		if err != nil {
+			t.Fatalf("open %s: %v", kind, err)
+		}
+		t.Cleanup(func() { st.Close() })
+		stores[kind] = st
+	}
+	return stores
+}
+
+func testIssue(number int, title string, updated time.Time) github.Issue {
+	return github.Issue{Number: number, Title: title, State: "open", CreatedAt: updated.AddDate(0, 0, -1), UpdatedAt: updated}
+}
+
+// numbers lists the number and title of issues, in order
+func numbers(issues []github.Issue) string {
+	var s string
+	for _, issue := range issues {
+		s += fmt.Sprintf("#%d %s; ", issue.Number, issue.Title)
+	}
+	return s
+}
+
+func TestStoreConformance(t *testing.T) {
+	day := time.Date(2025, 6, 3, 0, 0, 0, 0, time.UTC)
+	for kind, st := range openStores(t) {
+		t.Run(kind, func(t *testing.T) {
+			// fetch order, which isn't the order of ListIssues
+			if err := st.PutIssues("kokkos", "kokkos", []github.Issue{
+				testIssue(1, "oldest", day),
+				testIssue(3, "newest", day.Add(2*time.Hour)),
+				testIssue(2, "tie", day.Add(time.Hour)),
+				testIssue(4, "tie", day.Add(time.Hour)),
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if err := st.PutReleases("kokkos", "kokkos-kernels", []github.Release{{TagName: "4.6.01"}}); err != nil {
+				t.Fatal(err)
+			}
+
+			repos, err := st.ListRepos()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(repos) != "[{kokkos kokkos} {kokkos kokkos-kernels}]" {
+				t.Errorf("repos %v", repos)
+			}
+
+			issues, err := st.ListIssues("kokkos", "kokkos")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := numbers(issues), "#3 newest; #4 tie; #2 tie; #1 oldest; "; got != want {
+				t.Errorf("issues %s, want %s", got, want)
+			}
+
+			// upsert an existing issue and add a new one
+			if err := st.PutIssue("kokkos", "kokkos", testIssue(1, "updated", day.Add(3*time.Hour))); err != nil {
+				t.Fatal(err)
+			}
+			if err := st.PutIssue("kokkos", "kokkos", testIssue(5, "new", day.Add(30*time.Minute))); err != nil {
+				t.Fatal(err)
+			}
+			issues, err = st.ListIssues("kokkos", "kokkos")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := numbers(issues), "#1 updated; #3 newest; #4 tie; #2 tie; #5 new; "; got != want {
+				t.Errorf("issues after PutIssue %s, want %s", got, want)
+			}
+
+			// PutIssues replaces every issue of the repository
+			if err := st.PutIssues("kokkos", "kokkos", []github.Issue{testIssue(6, "only", day)}); err != nil {
+				t.Fatal(err)
+			}
+			issues, err = st.ListIssues("kokkos", "kokkos")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := numbers(issues), "#6 only; "; got != want {
+				t.Errorf("issues after PutIssues %s, want %s", got, want)
+			}
+
+			comments := []github.IssueComment{{ID: 7, Body: "first"}}
+			if err := st.PutComments("kokkos", "kokkos", 6, comments); err != nil {
+				t.Fatal(err)
+			}
+			comments[0].Body = "edited"
+			if err := st.PutComments("kokkos", "kokkos", 6, comments); err != nil {
+				t.Fatal(err)
+			}
+			stored, err := st.Comments("kokkos", "kokkos", 6)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(stored) != 1 || stored[0].Body != "edited" {
+				t.Errorf("comments %+v, want the edited one", stored)
+			}
+			releases, err := st.Releases("kokkos", "kokkos-kernels")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(releases) != 1 || releases[0].TagName != "4.6.01" {
+				t.Errorf("releases %+v", releases)
+			}
+
+			if err := st.Reset(); err != nil {
+				t.Fatal(err)
+			}
+			if issues, err := st.ListIssues("kokkos", "kokkos"); err != nil || len(issues) != 0 {
+				t.Errorf("issues after Reset %v, %v; want none", numbers(issues), err)
+			}
+			if comments, err := st.Comments("kokkos", "kokkos", 6); err != nil || len(comments) != 0 {
+				t.Errorf("comments after Reset %v, %v; want none", comments, err)
+			}
+			if releases, err := st.Releases("kokkos", "kokkos-kernels"); err != nil || len(releases) != 0 {
+				t.Errorf("releases after Reset %v, %v; want none", releases, err)
+			}
+
+			// the store is usable after Reset
+			if err := st.PutIssue("kokkos", "kokkos", testIssue(8, "after reset", day)); err != nil {
+				t.Fatal(err)
+			}
+			if issues, err := st.ListIssues("kokkos", "kokkos"); err != nil || numbers(issues) != "#8 after reset; " {
+				t.Errorf("issues after Reset and PutIssue %v, %v", numbers(issues), err)
+			}
+		})
+	}
+}
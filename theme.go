@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Link is a labelled URL in the page chrome
+type Link struct {
+	Text string `json:"text"`
+	URL  string `json:"url"`
+}
+
+// Theme is the branding of the site, read from theme.json
+type Theme struct {
+	Name        string            `json:"name"`       // of the project, e.g. in the digest heading
+	Title       string            `json:"title"`      // of the overview page
+	RepoTitle   string            `json:"repo_title"` // of the repo pages, with {repo} standing for owner/repo
+	Logo        string            `json:"logo"`       // URL, or path under the site root, of an image shown in the header
+	Copyright   Link              `json:"copyright"`
+	FooterLinks []Link            `json:"footer_links"`
+	FooterNotes []template.HTML   `json:"footer_notes"` // trusted like the templates, so they may hold links
+	Colors      map[string]string `json:"colors"`       // value of each --color-<name> CSS variable
+}
+
+var (
+	colorNamePattern  = regexp.MustCompile(`^[a-z0-9-]+$`)
+	colorValuePattern = regexp.MustCompile(`^[#a-zA-Z0-9(),.% -]+$`)
+)
+
+// loadTheme reads the built-in theme.json and then the theme directory's on top of it,
+// so a theme only has to set what it changes
+func loadTheme(config Config) (Theme, error) {
+	var theme Theme
+	data, err := builtinAssets.ReadFile("theme.json")
+	if err != nil {
+		return theme, err
+	}
+	if err := json.Unmarshal(data, &theme); err != nil {
+		return theme, fmt.Errorf("built-in theme.json: %w", err)
+	}
+
+	if config.ThemeDir != "" {
+		name := filepath.Join(config.ThemeDir, "theme.json")
+		data, err := os.ReadFile(name)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return theme, err
+		}
+		if err == nil {
+			if err := json.Unmarshal(data, &theme); err != nil {
+				return theme, fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+
+	for name, value := range theme.Colors {
+		if !colorNamePattern.MatchString(name) || !colorValuePattern.MatchString(value) {
+			return theme, fmt.Errorf("theme color %q: %q is not a CSS color", name, value)
+		}
+	}
+	return theme, nil
+}
+
+// RepoTitleOf is the title of the page of owner/repo
+func (t Theme) RepoTitleOf(owner, repo string) string {
+	return strings.ReplaceAll(t.RepoTitle, "{repo}", owner+"/"+repo)
+}
+
+// LogoURL is where pages load the logo from
+func (t Theme) LogoURL(siteRoot string) string {
+	if strings.Contains(t.Logo, "://") || strings.HasPrefix(t.Logo, "/") {
+		return t.Logo
+	}
+	return siteRoot + t.Logo
+}
+
+// Style declares the theme's colors as CSS variables, for a :root rule
+func (t Theme) Style() template.CSS {
+	var names []string
+	for name := range t.Colors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "--color-%s: %s; ", name, t.Colors[name])
+	}
+	return template.CSS(b.String())
+}
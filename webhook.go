@@ -0,0 +1,259 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"kokkos-dashboard/api"
+	"kokkos-dashboard/history"
+)
+
+// GitHub caps webhook payloads at 25 MB
+const maxWebhookPayload = 25 << 20
+
+// webhookPayload is the part of a GitHub webhook delivery that says what changed
+type webhookPayload struct {
+	Repository struct {
+		Name  string `json:"name"`
+		Owner struct {
+			Login string `json:"login"`
+		} `json:"owner"`
+	} `json:"repository"`
+	Issue *struct {
+		Number int `json:"number"`
+	} `json:"issue"`
+	PullRequest *struct {
+		Number int `json:"number"`
+	} `json:"pull_request"`
+}
+
+// number is the issue or pull request the delivery is about, or 0
+func (p webhookPayload) number() int {
+	if p.Issue != nil {
+		return p.Issue.Number
+	}
+	if p.PullRequest != nil {
+		return p.PullRequest.Number
+	}
+	return 0
+}
+
+// verifySignature checks an X-Hub-Signature-256 header against the HMAC of body
+func verifySignature(secret string, body []byte, header string) bool {
+	got, ok := strings.CutPrefix(header, "sha256=")
+	if !ok {
+		return false
+	}
+	gotMAC, err := hex.DecodeString(got)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(gotMAC, mac.Sum(nil))
+}
+
+// serveWebhook accepts GitHub webhook deliveries and updates the affected repo in the background
+func (r *refresher) serveWebhook(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxWebhookPayload))
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if !verifySignature(r.config.WebhookSecret, body, req.Header.Get("X-Hub-Signature-256")) {
+		http.Error(w, "bad signature", http.StatusUnauthorized)
+		return
+	}
+
+	event := req.Header.Get("X-GitHub-Event")
+	switch event {
+	case "ping":
+		w.WriteHeader(http.StatusOK)
+		return
+	case "issues", "issue_comment", "pull_request", "pull_request_review", "push", "release", "discussion", "discussion_comment", "workflow_run":
+	default:
+		log.Println("ignore webhook event", event)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var payload webhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		http.Error(w, "bad payload", http.StatusBadRequest)
+		return
+	}
+	owner, repo := payload.Repository.Owner.Login, payload.Repository.Name
+	if !r.tracks(owner, repo) {
+		log.Printf("ignore webhook for untracked %s/%s", owner, repo)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	number := payload.number()
+	log.Printf("webhook %s for %s/%s#%d", event, owner, repo, number)
+	go func() {
+		if err := r.update(owner, repo, event, number); err != nil {
+			log.Printf("webhook update error: %v", err)
+		}
+	}()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// tracks is true if owner/repo is one of the configured repositories
+func (r *refresher) tracks(owner, repo string) bool {
+	for _, configured := range r.config.Repositories {
+		if strings.EqualFold(configured.Owner, owner) && strings.EqualFold(configured.Name, repo) {
+			return true
+		}
+	}
+	return false
+}
+
+// update refetches what event changed: one issue (if number is not 0), the releases, the discussions,
+// the branch commits or the workflow runs.
+// Then it re-renders only the repo's page and API document, and the indexes that summarize them.
+func (r *refresher) update(owner, repo, event string, number int) error {
+	r.work.Lock()
+	defer r.work.Unlock()
+
+	config := r.config
+	config.Since = sinceWorkdays(config.Clock.Now(), config.Workdays)
+	config.OutputDir = *r.root.Load()
+
+	st, err := openStore(config)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	client := newGitHubClient(config)
+	switch event {
+	case "release":
+		published, err := client.GetReleases(owner, repo, config.Since)
+		if err != nil {
+			return err
+		}
+		if err := putReleases(client, st, owner, repo, published); err != nil {
+			return err
+		}
+	case "discussion", "discussion_comment":
+		discussionsClient := newDiscussionsClient(config)
+		if discussionsClient == nil {
+			return fmt.Errorf("can't fetch discussions: the GraphQL API needs credentials")
+		}
+		discussions, err := discussionsClient.GetRecentDiscussions(owner, repo, config.Since)
+		if err != nil {
+			return err
+		}
+		if err := putDiscussions(st, owner, repo, discussions, history.NewTally()); err != nil {
+			return err
+		}
+	case "push":
+		commits, err := getBranchCommits(newRESTClient(config), owner, repo, config.Branches, config.Since)
+		if err != nil {
+			return err
+		}
+		if err := putBranchCommits(st, owner, repo, commits, history.NewTally()); err != nil {
+			return err
+		}
+	case "workflow_run":
+		runs, err := getWorkflowRuns(newRESTClient(config), owner, repo, config.Since)
+		if err != nil {
+			return err
+		}
+		if err := st.PutWorkflowRuns(owner, repo, runs); err != nil {
+			return err
+		}
+	}
+	if number != 0 {
+		issue, err := client.GetIssue(owner, repo, number)
+		if err != nil {
+			return err
+		}
+		if err := st.PutIssue(owner, repo, *issue); err != nil {
+			return err
+		}
+		details, err := client.GetIssueDetails(owner, repo, *issue, config.Since)
+		if err != nil {
+			return err
+		}
+		// the daily history is recorded by full fetches, so this tally covers no days
+		if err := putIssueDetails(st, owner, repo, *details, history.NewTally()); err != nil {
+			return err
+		}
+	}
+
+	repoData, err := loadRepos(st, config)
+	if err != nil {
+		return err
+	}
+	var repoKeys []string
+	for key := range repoData {
+		repoKeys = append(repoKeys, key)
+	}
+	sort.Strings(repoKeys)
+	data, ok := repoData[owner+"/"+repo]
+	if !ok {
+		return fmt.Errorf("%s/%s is not in the store", owner, repo)
+	}
+
+	tmpl, err := parseTemplates(config)
+	if err != nil {
+		return err
+	}
+	theme, err := loadTheme(config)
+	if err != nil {
+		return err
+	}
+
+	// the index shows every repo's counts, so it changes with this one
+	var repos []*RepoData
+	var refs []api.RepoRef
+	for _, key := range repoKeys {
+		repos = append(repos, repoData[key])
+		refs = append(refs, apiRepoRef(repoData[key]))
+	}
+
+	buildDate := config.Clock.Now()
+	navRepos := navReposOf(repoKeys)
+	if err := renderRepoPage(tmpl, data, navRepos, theme, config, buildDate); err != nil {
+		return err
+	}
+	if err := renderIndexPage(tmpl, repos, navRepos, theme, config, buildDate); err != nil {
+		return err
+	}
+	if _, err := renderAPIRepo(data, config, buildDate); err != nil {
+		return err
+	}
+	if err := renderAPIIndex(refs, config, buildDate); err != nil {
+		return err
+	}
+
+	apiDir := filepath.Join(config.OutputDir, "api", api.Version)
+	for _, name := range []string{
+		filepath.Join(config.OutputDir, owner, repo, "index.html"),
+		filepath.Join(config.OutputDir, "index.html"),
+		filepath.Join(apiDir, owner, repo+".json"),
+		filepath.Join(apiDir, "index.json"),
+	} {
+		if err := compressFile(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kokkos-dashboard/api"
+)
+
+const testWebhookSecret = "It's a Secret to Everybody"
+
+// delivery reads a recorded webhook delivery from testdata/webhooks
+func delivery(t *testing.T, event string) []byte {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", "webhooks", event+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+// sign computes the X-Hub-Signature-256 GitHub sends with body
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// deliver posts body to r's webhook receiver as event and returns the response status
+func deliver(r *refresher, event, signature string, body []byte) int {
+	req := httptest.NewRequest("POST", "/webhook", bytes.NewReader(body))
+	req.Header.Set("X-GitHub-Event", event)
+	req.Header.Set("X-Hub-Signature-256", signature)
+	w := httptest.NewRecorder()
+	r.serveWebhook(w, req)
+	return w.Code
+}
+
+func TestWebhookRejectsBadSignature(t *testing.T) {
+	config := defaultConfig()
+	config.WebhookSecret = testWebhookSecret
+	r := newRefresher(config, 0)
+
+	for _, event := range []string{"issues", "pull_request", "issue_comment"} {
+		body := delivery(t, event)
+		for _, signature := range []string{
+			"",
+			sign("another secret", body),
+			sign(testWebhookSecret, append(body, ' ')),
+			strings.TrimPrefix(sign(testWebhookSecret, body), "sha256="),
+		} {
+			if code := deliver(r, event, signature, body); code != http.StatusUnauthorized {
+				t.Errorf("%s signed %q: status %d, want %d", event, signature, code, http.StatusUnauthorized)
+			}
+		}
+	}
+}
+
+func TestWebhookIgnoresUntrackedRepo(t *testing.T) {
+	config := defaultConfig()
+	config.WebhookSecret = testWebhookSecret
+	config.Repositories = config.Repositories[1:2] // kokkos/kokkos-kernels, while the deliveries are for kokkos/kokkos
+	r := newRefresher(config, 0)
+
+	for _, event := range []string{"issues", "pull_request", "issue_comment"} {
+		body := delivery(t, event)
+		if code := deliver(r, event, sign(testWebhookSecret, body), body); code != http.StatusNoContent {
+			t.Errorf("%s: status %d, want %d", event, code, http.StatusNoContent)
+		}
+	}
+}
+
+func TestWebhookIgnoresUnsupportedEvent(t *testing.T) {
+	config := defaultConfig()
+	config.WebhookSecret = testWebhookSecret
+	r := newRefresher(config, 0)
+
+	body := delivery(t, "issues")
+	for event, want := range map[string]int{
+		"ping":  http.StatusOK,
+		"star":  http.StatusNoContent,
+		"fork":  http.StatusNoContent,
+		"label": http.StatusNoContent,
+	} {
+		if code := deliver(r, event, sign(testWebhookSecret, body), body); code != want {
+			t.Errorf("%s: status %d, want %d", event, code, want)
+		}
+	}
+}
+
+func TestWebhookPayloadNumber(t *testing.T) {
+	for event, want := range map[string]int{
+		"issues":        102,
+		"pull_request":  101,
+		"issue_comment": 101,
+	} {
+		var payload webhookPayload
+		if err := json.Unmarshal(delivery(t, event), &payload); err != nil {
+			t.Fatal(err)
+		}
+		if payload.Repository.Owner.Login != "kokkos" || payload.Repository.Name != "kokkos" || payload.number() != want {
+			t.Errorf("%s is about %s/%s#%d, want kokkos/kokkos#%d",
+				event, payload.Repository.Owner.Login, payload.Repository.Name, payload.number(), want)
+		}
+	}
+}
+
+// TestWebhookUpdatesIssue starts from testdata/data without kokkos/kokkos#102,
+// and updates it from the fixtures as the issues delivery about it asks
+func TestWebhookUpdatesIssue(t *testing.T) {
+	config := replayConfig(t)
+	if err := os.CopyFS(config.FetchDir, os.DirFS(filepath.Join("testdata", "data"))); err != nil {
+		t.Fatal(err)
+	}
+	repoDir := filepath.Join(config.FetchDir, "kokkos", "kokkos")
+	withoutIssue(t, filepath.Join(repoDir, "issues.json"), 102)
+	if err := os.RemoveAll(filepath.Join(repoDir, "issues", "102")); err != nil {
+		t.Fatal(err)
+	}
+	if err := render(config); err != nil {
+		t.Fatal(err)
+	}
+	if got := indexIssues(t, config); got != 1 {
+		t.Fatalf("index.json has %d kokkos/kokkos issues before the update, want 1", got)
+	}
+
+	var payload webhookPayload
+	if err := json.Unmarshal(delivery(t, "issues"), &payload); err != nil {
+		t.Fatal(err)
+	}
+	r := newRefresher(config, 0)
+	if err := r.update(payload.Repository.Owner.Login, payload.Repository.Name, "issues", payload.number()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, page := range []string{
+		filepath.Join(config.OutputDir, "kokkos", "kokkos", "index.html"),
+		filepath.Join(config.OutputDir, "index.html"),
+	} {
+		html, err := os.ReadFile(page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(html), "Crash with CUDA 12") {
+			t.Errorf("%s lacks the updated issue", page)
+		}
+	}
+	if got := indexIssues(t, config); got != 2 {
+		t.Errorf("index.json has %d kokkos/kokkos issues after the update, want 2", got)
+	}
+	var repo api.Repo
+	readJSON(t, filepath.Join(config.OutputDir, "api", api.Version, "kokkos", "kokkos.json"), &repo)
+	if len(repo.Issues) != 2 {
+		t.Errorf("kokkos/kokkos.json has %d issues after the update, want 2", len(repo.Issues))
+	}
+}
+
+// graphQLIssue102 answers the GraphQL queries for kokkos/kokkos#102 with the fields they select, as GitHub does
+func graphQLIssue102(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Query string `json:"query"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("request body: %v", err)
+		}
+		issue := `"__typename": "Issue", "id": "I_102", "number": 102, "title": "Crash with CUDA 12", "state": "CLOSED",
+			"createdAt": "2025-05-20T10:00:00Z", "updatedAt": "2025-06-03T15:00:00Z", "closedAt": "2025-06-03T15:00:00Z",
+			"url": "https://github.com/kokkos/kokkos/issues/102", "author": {"login": "dave"}`
+		if strings.Contains(req.Query, "reactionGroups") {
+			issue += `, "reactionGroups": [{"content": "THUMBS_UP", "reactors": {"totalCount": 4}}, {"content": "EYES", "reactors": {"totalCount": 1}}]`
+		}
+		if strings.Contains(req.Query, "comments(first:") {
+			issue += `, "comments": {"nodes": [], "pageInfo": {"hasNextPage": false}}, "timelineItems": {"nodes": [], "pageInfo": {"hasNextPage": false}}`
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.Contains(req.Query, "issueOrPullRequest(number:"):
+			fmt.Fprintf(w, `{"data": {"repository": {"issueOrPullRequest": {%s}}}}`, issue)
+		case strings.Contains(req.Query, "issue(number:"):
+			fmt.Fprintf(w, `{"data": {"repository": {"issue": {%s}}}}`, issue)
+		default:
+			t.Errorf("unexpected query %s", req.Query)
+			fmt.Fprint(w, `{"errors": [{"message": "unexpected query"}]}`)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// TestWebhookKeepsReactionsWithGraphQL updates kokkos/kokkos#102 through the GraphQL backend,
+// which must not lose the reactions the most upvoted issues are ranked by
+func TestWebhookKeepsReactionsWithGraphQL(t *testing.T) {
+	config := goldenConfig(t)
+	config.FetchDir = t.TempDir()
+	if err := os.CopyFS(config.FetchDir, os.DirFS(filepath.Join("testdata", "data"))); err != nil {
+		t.Fatal(err)
+	}
+	config.GitHubBackend = "graphql"
+	config.GitHubGraphQLURL = graphQLIssue102(t).URL
+	config.GitHubToken = "test-token"
+	if err := setupGitHubAuth(&config); err != nil {
+		t.Fatal(err)
+	}
+	if err := render(config); err != nil {
+		t.Fatal(err)
+	}
+
+	r := newRefresher(config, 0)
+	if err := r.update("kokkos", "kokkos", "issues", 102); err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := openStore(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	issues, err := st.ListIssues("kokkos", "kokkos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, issue := range issues {
+		if issue.Number == 102 {
+			if issue.Reactions.PlusOne != 4 || issue.Reactions.TotalCount != 5 {
+				t.Errorf("reactions of #102 after the update %+v, want 4 +1 of 5", issue.Reactions)
+			}
+			return
+		}
+	}
+	t.Error("#102 is not in the store after the update")
+}
+
+// withoutIssue removes an issue from the issues.json of an fs store
+func withoutIssue(t *testing.T, name string, number int) {
+	t.Helper()
+	var issues []map[string]any
+	readJSON(t, name, &issues)
+	var kept []map[string]any
+	for _, issue := range issues {
+		if issue["number"] != float64(number) {
+			kept = append(kept, issue)
+		}
+	}
+	data, err := json.Marshal(kept)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// indexIssues is the number of kokkos/kokkos issues in the rendered api/v1/index.json
+func indexIssues(t *testing.T, config Config) int {
+	t.Helper()
+	var index api.Index
+	readJSON(t, filepath.Join(config.OutputDir, "api", api.Version, "index.json"), &index)
+	for _, ref := range index.Repos {
+		if ref.Owner == "kokkos" && ref.Name == "kokkos" {
+			return ref.Issues
+		}
+	}
+	return 0
+}
+
+func readJSON(t *testing.T, name string, v any) {
+	t.Helper()
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+}